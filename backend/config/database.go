@@ -14,6 +14,9 @@ import (
 
 var DB *gorm.DB
 
+// SearchLanguage is the text search configuration used to index and query message content
+const SearchLanguage = "english"
+
 // ConnectDatabase initializes the database connection
 func ConnectDatabase() {
 	host := getEnv("DB_HOST", "localhost")
@@ -43,12 +46,54 @@ func MigrateDB() {
 		&models.User{},
 		&models.Channel{},
 		&models.Message{},
+		&models.MessageRevision{},
+		&models.Blob{},
+		&models.Stamp{},
+		&models.Reaction{},
+		&models.Mention{},
+		&models.ChannelReadState{},
+		&models.ChannelLastPost{},
+		&models.IdempotencyKey{},
+		&models.ScheduledMessage{},
+		&models.MessageReport{},
+		&models.Sanction{},
+		&models.AuditLog{},
+		&models.FilterRule{},
+		&models.ChannelMember{},
+		&models.ChannelInvite{},
+		&models.IncomingWebhook{},
+		&models.OutgoingWebhook{},
+		&models.WebhookDelivery{},
+		&models.WebhookDeliveryAttempt{},
 	)
 
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
 
+	// Full-text search index on message content, maintained by PostgreSQL
+	if err := DB.Exec(fmt.Sprintf(`ALTER TABLE messages ADD COLUMN IF NOT EXISTS content_tsv tsvector
+		GENERATED ALWAYS AS (to_tsvector('%s', coalesce(content, ''))) STORED`, SearchLanguage)).Error; err != nil {
+		log.Fatal("Failed to add message search column:", err)
+	}
+	if err := DB.Exec("CREATE INDEX IF NOT EXISTS idx_messages_content_tsv ON messages USING GIN (content_tsv)").Error; err != nil {
+		log.Fatal("Failed to create message search index:", err)
+	}
+
+	// The audit log is append-only, even for direct database access
+	if err := DB.Exec(`CREATE OR REPLACE FUNCTION audit_logs_append_only() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'audit log entries cannot be modified or deleted';
+		END;
+		$$ LANGUAGE plpgsql`).Error; err != nil {
+		log.Fatal("Failed to create audit log guard:", err)
+	}
+	if err := DB.Exec(`CREATE OR REPLACE TRIGGER audit_logs_append_only
+		BEFORE UPDATE OR DELETE ON audit_logs
+		FOR EACH ROW EXECUTE FUNCTION audit_logs_append_only()`).Error; err != nil {
+		log.Fatal("Failed to create audit log guard:", err)
+	}
+
 	log.Println("Database migration completed successfully")
 }
 
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Default time during which authors can edit their messages
+const defaultMessageEditWindow = 15 * time.Minute
+
+// GetMessageEditWindow returns how long after posting a message can be edited,
+// from the MESSAGE_EDIT_WINDOW environment variable (e.g. "15m", "0" for no limit)
+func GetMessageEditWindow() time.Duration {
+	value := os.Getenv("MESSAGE_EDIT_WINDOW")
+	if value == "" {
+		return defaultMessageEditWindow
+	}
+
+	window, err := time.ParseDuration(value)
+	if err != nil || window < 0 {
+		log.Printf("Invalid MESSAGE_EDIT_WINDOW %q, using %s", value, defaultMessageEditWindow)
+		return defaultMessageEditWindow
+	}
+	return window
+}
+
+// Default time during which an idempotency key replays the original message
+const defaultIdempotencyKeyTTL = 24 * time.Hour
+
+// GetIdempotencyKeyTTL returns how long idempotency keys are remembered,
+// from the IDEMPOTENCY_KEY_TTL environment variable (e.g. "24h")
+func GetIdempotencyKeyTTL() time.Duration {
+	value := os.Getenv("IDEMPOTENCY_KEY_TTL")
+	if value == "" {
+		return defaultIdempotencyKeyTTL
+	}
+
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		log.Printf("Invalid IDEMPOTENCY_KEY_TTL %q, using %s", value, defaultIdempotencyKeyTTL)
+		return defaultIdempotencyKeyTTL
+	}
+	return ttl
+}
+
+// Default time during which posting the same message again is rejected
+const defaultDuplicateMessageWindow = time.Minute
+
+// GetDuplicateMessageWindow returns how long a user cannot post the same message twice,
+// from the DUPLICATE_MESSAGE_WINDOW environment variable (e.g. "1m", "0" to allow duplicates)
+func GetDuplicateMessageWindow() time.Duration {
+	value := os.Getenv("DUPLICATE_MESSAGE_WINDOW")
+	if value == "" {
+		return defaultDuplicateMessageWindow
+	}
+
+	window, err := time.ParseDuration(value)
+	if err != nil || window < 0 {
+		log.Printf("Invalid DUPLICATE_MESSAGE_WINDOW %q, using %s", value, defaultDuplicateMessageWindow)
+		return defaultDuplicateMessageWindow
+	}
+	return window
+}
+
+// Default maximum number of links in a message
+const defaultMaxLinksPerMessage = 5
+
+// GetMaxLinksPerMessage returns how many links a message may contain,
+// from the MAX_LINKS_PER_MESSAGE environment variable ("-1" for no limit)
+func GetMaxLinksPerMessage() int {
+	value := os.Getenv("MAX_LINKS_PER_MESSAGE")
+	if value == "" {
+		return defaultMaxLinksPerMessage
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < -1 {
+		log.Printf("Invalid MAX_LINKS_PER_MESSAGE %q, using %d", value, defaultMaxLinksPerMessage)
+		return defaultMaxLinksPerMessage
+	}
+	return limit
+}
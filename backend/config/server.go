@@ -0,0 +1,18 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// GetTrustedProxies returns the proxies whose X-Forwarded-For header is trusted for client IPs,
+// from the comma separated TRUSTED_PROXIES environment variable (IPs or CIDRs). None by default.
+func GetTrustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
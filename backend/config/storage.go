@@ -0,0 +1,67 @@
+package config
+
+import (
+	"os"
+	"strconv"
+)
+
+// Blob store backends
+const (
+	BlobStoreFilesystem = "fs"
+	BlobStoreS3         = "s3"
+)
+
+// Default directory of the filesystem blob store
+const defaultBlobDir = "./data/blobs"
+
+// S3Config holds the settings of an S3-compatible blob store
+type S3Config struct {
+	Endpoint        string // e.g. https://s3.eu-west-3.amazonaws.com or http://minio:9000
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	PathStyle       bool   // Bucket in the path instead of the host name, as MinIO expects
+	Prefix          string // Prepended to the keys, e.g. "drawings/"
+}
+
+// GetBlobStore returns where drawings are stored, from the BLOB_STORE environment variable ("fs" or "s3")
+func GetBlobStore() string {
+	if store := os.Getenv("BLOB_STORE"); store != "" {
+		return store
+	}
+	return BlobStoreFilesystem
+}
+
+// GetBlobDir returns the directory of the filesystem blob store, from the BLOB_DIR environment variable
+func GetBlobDir() string {
+	if dir := os.Getenv("BLOB_DIR"); dir != "" {
+		return dir
+	}
+	return defaultBlobDir
+}
+
+// GetS3Config returns the S3 blob store settings from the S3_* environment variables
+func GetS3Config() S3Config {
+	region := os.Getenv("S3_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	pathStyle := true
+	if value := os.Getenv("S3_PATH_STYLE"); value != "" {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			pathStyle = parsed
+		}
+	}
+
+	return S3Config{
+		Endpoint:        os.Getenv("S3_ENDPOINT"),
+		Region:          region,
+		Bucket:          os.Getenv("S3_BUCKET"),
+		AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		PathStyle:       pathStyle,
+		Prefix:          os.Getenv("S3_PREFIX"),
+	}
+}
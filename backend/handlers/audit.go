@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"pictorial-backend/config"
+	"pictorial-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// Default and maximum number of audit log entries listed at once
+	defaultAuditLimit = 50
+	maxAuditLimit     = 200
+
+	// Number of entries read at once by an export
+	auditExportBatchSize = 500
+)
+
+// auditEntry describes a privileged operation for the audit log
+type auditEntry struct {
+	Action     string
+	TargetType string
+	TargetID   uint        // 0 when the operation has no single target
+	Before     interface{} // State of the target before the operation, nil for creations
+	After      interface{} // State of the target after the operation, nil for deletions
+	Details    interface{} // Other action-specific data
+}
+
+// AuditLogPage is a page of the audit log, newest entries first
+type AuditLogPage struct {
+	Entries    []models.AuditLogResponse `json:"entries"`
+	HasMore    bool                      `json:"has_more"`
+	NextCursor *uint                     `json:"next_cursor"` // Pass as before to get the next page
+}
+
+// marshalAuditValue encodes a snapshot, nil stays empty
+func marshalAuditValue(value interface{}) (models.RawJSON, error) {
+	if value == nil {
+		return "", nil
+	}
+	body, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return models.RawJSON(body), nil
+}
+
+// recordAudit appends an entry to the audit log, in the transaction of the audited operation
+func recordAudit(tx *gorm.DB, c *gin.Context, actorID uint, entry auditEntry) error {
+	record := models.AuditLog{
+		ActorID:    actorID,
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		IP:         c.ClientIP(),
+	}
+	if entry.TargetID != 0 {
+		record.TargetID = &entry.TargetID
+	}
+
+	var err error
+	if record.Before, err = marshalAuditValue(entry.Before); err != nil {
+		return err
+	}
+	if record.After, err = marshalAuditValue(entry.After); err != nil {
+		return err
+	}
+	if record.Details, err = marshalAuditValue(entry.Details); err != nil {
+		return err
+	}
+
+	return tx.Create(&record).Error
+}
+
+// messageAuditSnapshot is the state of a message kept in the audit log, without its drawing
+func messageAuditSnapshot(message *models.Message) gin.H {
+	return gin.H{
+		"id":          message.ID,
+		"channel_id":  message.ChannelID,
+		"user_id":     message.UserID,
+		"parent_id":   message.ParentID,
+		"content":     message.Content,
+		"has_image":   message.HasImage(),
+		"nb_of_lines": message.NbOfLines,
+		"pinned_at":   message.PinnedAt,
+		"created_at":  message.CreatedAt,
+	}
+}
+
+// auditFilters parses the audit log filters of the query string, writing a 400 response if one is invalid
+func auditFilters(c *gin.Context) (func(*gorm.DB) *gorm.DB, bool) {
+	var conditions []func(*gorm.DB) *gorm.DB
+
+	for _, param := range []string{"actor_id", "target_id"} {
+		raw := c.Query(param)
+		if raw == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param})
+			return nil, false
+		}
+		column := param
+		conditions = append(conditions, func(db *gorm.DB) *gorm.DB {
+			return db.Where(column+" = ?", id)
+		})
+	}
+
+	for _, param := range []string{"action", "target_type"} {
+		if value := c.Query(param); value != "" {
+			column := param
+			conditions = append(conditions, func(db *gorm.DB) *gorm.DB {
+				return db.Where(column+" = ?", value)
+			})
+		}
+	}
+
+	for _, param := range []string{"from", "to"} {
+		raw := c.Query(param)
+		if raw == "" {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid %s, expected an RFC 3339 time", param)})
+			return nil, false
+		}
+		operator := ">="
+		if param == "to" {
+			operator = "<"
+		}
+		conditions = append(conditions, func(db *gorm.DB) *gorm.DB {
+			return db.Where("created_at "+operator+" ?", at)
+		})
+	}
+
+	return func(db *gorm.DB) *gorm.DB {
+		for _, condition := range conditions {
+			db = condition(db)
+		}
+		return db
+	}, true
+}
+
+// GetAuditLog lists the audit log, newest entries first (admin only)
+func GetAuditLog(c *gin.Context) {
+	filters, ok := auditFilters(c)
+	if !ok {
+		return
+	}
+
+	limit := defaultAuditLimit
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		if parsed < maxAuditLimit {
+			limit = parsed
+		} else {
+			limit = maxAuditLimit
+		}
+	}
+
+	query := config.DB.Preload("Actor").Scopes(filters)
+	if raw := c.Query("before"); raw != "" {
+		before, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid before"})
+			return
+		}
+		query = query.Where("id < ?", before)
+	}
+
+	// One more entry than requested tells if there is another page
+	var entries []models.AuditLog
+	if err := query.Order("id desc").Limit(limit + 1).Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit log"})
+		return
+	}
+
+	page := AuditLogPage{Entries: []models.AuditLogResponse{}}
+	if len(entries) > limit {
+		entries = entries[:limit]
+		page.HasMore = true
+		page.NextCursor = &entries[limit-1].ID
+	}
+	for _, entry := range entries {
+		page.Entries = append(page.Entries, entry.ToResponse())
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// auditExportSummary ends an export, so that a truncated file can be told apart from a complete one
+type auditExportSummary struct {
+	Complete bool   `json:"complete"`
+	Entries  int    `json:"entries"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ExportAuditLog streams the entries matching the filters as JSON lines, oldest first, followed by
+// a summary line (admin only). The export itself is recorded in the audit log, and included in it.
+func ExportAuditLog(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	filters, ok := auditFilters(c)
+	if !ok {
+		return
+	}
+
+	if err := recordAudit(config.DB, c, userID.(uint), auditEntry{
+		Action:  models.AuditLogExported,
+		Details: c.Request.URL.Query(),
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export audit log"})
+		return
+	}
+
+	// Entries appended while exporting are left out, the export's own entry is already there
+	var lastID uint
+	if err := config.DB.Model(&models.AuditLog{}).Select("COALESCE(MAX(id), 0)").Scan(&lastID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export audit log"})
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="audit-%s.jsonl"`, time.Now().UTC().Format("20060102-150405")))
+	c.Status(http.StatusOK)
+
+	encoder := json.NewEncoder(c.Writer)
+	summary := auditExportSummary{}
+	var cursor uint
+	for {
+		var batch []models.AuditLog
+		if err := config.DB.Preload("Actor").Scopes(filters).
+			Where("id > ? AND id <= ?", cursor, lastID).
+			Order("id").
+			Limit(auditExportBatchSize).
+			Find(&batch).Error; err != nil {
+			// The status is already sent, the summary tells the export ended early
+			log.Printf("Failed to export audit log: %v", err)
+			summary.Error = "Failed to read the audit log, the export is incomplete"
+			break
+		}
+
+		for _, entry := range batch {
+			if err := encoder.Encode(entry.ToResponse()); err != nil {
+				return
+			}
+			summary.Entries++
+		}
+		c.Writer.Flush()
+
+		if len(batch) < auditExportBatchSize {
+			summary.Complete = true
+			break
+		}
+		cursor = batch[len(batch)-1].ID
+	}
+
+	encoder.Encode(gin.H{"export": summary})
+	c.Writer.Flush()
+}
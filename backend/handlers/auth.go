@@ -6,6 +6,7 @@ import (
 	"pictorial-backend/config"
 	"pictorial-backend/models"
 	"pictorial-backend/utils"
+	"pictorial-backend/webhooks"
 
 	"github.com/gin-gonic/gin"
 )
@@ -36,6 +37,11 @@ func Register(c *gin.Context) {
 		return
 	}
 
+	if reqErr := checkUsername(req.Name); reqErr != nil {
+		reqErr.respond(c)
+		return
+	}
+
 	// Hash password
 	hashedPassword, err := utils.HashPassword(req.Password)
 	if err != nil {
@@ -55,6 +61,8 @@ func Register(c *gin.Context) {
 		return
 	}
 
+	emitWebhookEvent(webhooks.EventUserRegistered, user.ToResponse())
+
 	// Generate token
 	token, err := utils.GenerateToken(user.ID, user.Name, config.GetJWTSecret())
 	if err != nil {
@@ -89,6 +97,15 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	// Banned users cannot log in
+	if sanction, err := activeSanction(user.ID, 0, models.SanctionBan); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check sanctions"})
+		return
+	} else if sanction != nil {
+		sanctionError(sanction).respond(c)
+		return
+	}
+
 	// Generate token
 	token, err := utils.GenerateToken(user.ID, user.Name, config.GetJWTSecret())
 	if err != nil {
@@ -118,3 +135,20 @@ func GetCurrentUser(c *gin.Context) {
 
 	c.JSON(http.StatusOK, user.ToResponse())
 }
+
+// loadCurrentUser fetches the authenticated user, writing an error response if it fails
+func loadCurrentUser(c *gin.Context) (*models.User, bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return nil, false
+	}
+
+	return &user, true
+}
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"pictorial-backend/config"
+	"pictorial-backend/models"
+	"pictorial-backend/storage"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Blobs stores the drawings
+var Blobs storage.Store
+
+const (
+	// Time allowed for a blob store operation
+	blobTimeout = 30 * time.Second
+
+	// Unreferenced blobs are kept this long after they were last stored,
+	// so that a drawing being posted again is never collected before its message is saved
+	blobGracePeriod = time.Hour
+
+	// How often unreferenced blobs are collected
+	blobCollectInterval = time.Hour
+
+	// Number of blobs collected, or of images migrated, per batch
+	blobBatchSize = 100
+)
+
+// Tables holding drawings, each with an image_key column and the image column used before the blob store
+var drawingTables = []string{"messages", "message_revisions", "message_reports", "scheduled_messages"}
+
+// unreferencedBlob matches the blobs no drawing refers to. Soft-deleted messages keep theirs, they can be restored.
+const unreferencedBlob = "NOT EXISTS (SELECT 1 FROM messages WHERE messages.image_key = blobs.key) " +
+	"AND NOT EXISTS (SELECT 1 FROM message_revisions WHERE message_revisions.image_key = blobs.key) " +
+	"AND NOT EXISTS (SELECT 1 FROM message_reports WHERE message_reports.image_key = blobs.key) " +
+	"AND NOT EXISTS (SELECT 1 FROM scheduled_messages WHERE scheduled_messages.image_key = blobs.key)"
+
+// hasDrawing matches the messages with a drawing, migrated to the blob store or not
+const hasDrawing = "(messages.image_key IS NOT NULL OR COALESCE(octet_length(messages.image), 0) > 0)"
+
+// storeDrawing puts a drawing in the blob store and returns its key. Identical drawings are stored once.
+// The blob is registered, or its last use bumped, before the upload so that the collector leaves it alone.
+func storeDrawing(data []byte) (string, error) {
+	key := storage.Key(data)
+	now := time.Now()
+
+	blob := models.Blob{Key: key, Size: int64(len(data)), LastUsedAt: now}
+	if err := config.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"last_used_at": now}),
+	}).Create(&blob).Error; err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), blobTimeout)
+	defer cancel()
+
+	exists, err := Blobs.Exists(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return key, nil
+	}
+	return key, Blobs.Put(ctx, key, data)
+}
+
+// drawingKey returns the content address of a drawing, computed for drawings not migrated yet, empty without a drawing
+func drawingKey(key *string, legacy []byte) string {
+	if key != nil {
+		return *key
+	}
+	if len(legacy) > 0 {
+		return storage.Key(legacy)
+	}
+	return ""
+}
+
+// readDrawing loads a whole drawing, from the blob store or from the image column
+func readDrawing(key *string, legacy []byte) ([]byte, error) {
+	if key == nil {
+		return legacy, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), blobTimeout)
+	defer cancel()
+
+	reader, _, err := Blobs.Get(ctx, *key)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(io.LimitReader(reader, maxImageBytes+1))
+}
+
+// serveDrawing streams a drawing from the blob store, or writes the bytes of a drawing not migrated yet.
+// The key of stored drawings is their ETag, so clients can revalidate cached drawings cheaply.
+func serveDrawing(c *gin.Context, key *string, legacy []byte) {
+	if key == nil {
+		servePNG(c, legacy)
+		return
+	}
+
+	etag := `"` + *key + `"`
+	c.Header("ETag", etag)
+	c.Header("X-Content-Type-Options", "nosniff")
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	reader, size, err := Blobs.Get(c.Request.Context(), *key)
+	if errors.Is(err, storage.ErrNotFound) {
+		log.Printf("Drawing %s is missing from the blob store", *key)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to read drawing %s: %v", *key, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch image"})
+		return
+	}
+	defer reader.Close()
+
+	// Migrated images were stored as they were, only PNGs are labelled as such
+	buffered := bufio.NewReader(reader)
+	contentType := "image/png"
+	extraHeaders := map[string]string{}
+	if signature, _ := buffered.Peek(len(pngSignature)); !bytes.Equal(signature, pngSignature) {
+		contentType = "application/octet-stream"
+		extraHeaders["Content-Disposition"] = "attachment"
+	}
+	c.DataFromReader(http.StatusOK, size, contentType, buffered, extraHeaders)
+}
+
+// collectBlobs deletes a batch of blobs nothing refers to anymore and returns how many were deleted
+func collectBlobs() (int, error) {
+	cutoff := time.Now().Add(-blobGracePeriod)
+
+	var keys []string
+	if err := config.DB.Model(&models.Blob{}).
+		Where("last_used_at < ?", cutoff).
+		Where(unreferencedBlob).
+		Limit(blobBatchSize).
+		Pluck("key", &keys).Error; err != nil {
+		return 0, err
+	}
+
+	collected := 0
+	for _, key := range keys {
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			// Storing the same drawing again waits for the lock, the conditions are checked again once it is held
+			var locked []models.Blob
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("key = ? AND last_used_at < ?", key, cutoff).
+				Where(unreferencedBlob).
+				Find(&locked).Error; err != nil {
+				return err
+			}
+			if len(locked) == 0 {
+				return nil
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), blobTimeout)
+			defer cancel()
+			if err := Blobs.Delete(ctx, key); err != nil {
+				return err
+			}
+			collected++
+			return tx.Delete(&models.Blob{}, "key = ?", key).Error
+		})
+		if err != nil {
+			return collected, err
+		}
+	}
+	return collected, nil
+}
+
+// RunBlobCollector periodically deletes the drawings of purged messages, revisions, reports and scheduled messages
+func RunBlobCollector() {
+	for {
+		for {
+			collected, err := collectBlobs()
+			if err != nil {
+				log.Printf("Blob collector error: %v", err)
+				break
+			}
+			if collected < blobBatchSize {
+				break
+			}
+		}
+		time.Sleep(blobCollectInterval)
+	}
+}
+
+// legacyImage is a drawing still stored in an image column
+type legacyImage struct {
+	ID    uint
+	Image []byte
+}
+
+// migrateTableImages moves the drawings of a table to the blob store and returns how many were moved
+func migrateTableImages(table string) (int, error) {
+	moved := 0
+	var cursor uint
+	for {
+		var rows []legacyImage
+		if err := config.DB.Table(table).
+			Select("id, image").
+			Where("id > ? AND image IS NOT NULL", cursor).
+			Order("id").
+			Limit(blobBatchSize).
+			Scan(&rows).Error; err != nil {
+			return moved, err
+		}
+		if len(rows) == 0 {
+			return moved, nil
+		}
+
+		for _, row := range rows {
+			cursor = row.ID
+
+			updates := map[string]interface{}{"image": nil}
+			if len(row.Image) > 0 {
+				key, err := storeDrawing(row.Image)
+				if err != nil {
+					return moved, err
+				}
+				updates["image_key"] = key
+			}
+
+			// Rows whose drawing changed in the meantime are left for the next run
+			result := config.DB.Table(table).Where("id = ? AND image = ?", row.ID, row.Image).UpdateColumns(updates)
+			if result.Error != nil {
+				return moved, result.Error
+			}
+			if result.RowsAffected > 0 && len(row.Image) > 0 {
+				moved++
+			}
+		}
+	}
+}
+
+// MigrateImages moves the drawings stored in the database to the blob store. It can be stopped and run again.
+func MigrateImages() error {
+	for _, table := range drawingTables {
+		moved, err := migrateTableImages(table)
+		log.Printf("Moved %d drawings of %s to the blob store", moved, table)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,144 +1,403 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
-	"strconv"
 
 	"pictorial-backend/config"
 	"pictorial-backend/models"
+	"pictorial-backend/webhooks"
+	ws "pictorial-backend/websocket"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+// canAccessChannel reports whether a user may see a channel and its messages
+func canAccessChannel(user *models.User, channel *models.Channel) bool {
+	if !channel.IsPrivate || user.IsAdmin() || channel.IsOwnedBy(user.ID) {
+		return true
+	}
+
+	var count int64
+	config.DB.Model(&models.ChannelMember{}).
+		Where("channel_id = ? AND user_id = ?", channel.ID, user.ID).
+		Count(&count)
+	return count > 0
+}
+
+// canManageChannel reports whether a user may administer a channel (admins and the channel owner)
+func canManageChannel(user *models.User, channel *models.Channel) bool {
+	return user.IsAdmin() || channel.IsOwnedBy(user.ID)
+}
+
+// accessibleChannels restricts a channel query to the channels a user may see
+func accessibleChannels(user *models.User) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if user.IsAdmin() {
+			return db
+		}
+		memberships := config.DB.Model(&models.ChannelMember{}).Select("channel_id").Where("user_id = ?", user.ID)
+		return db.Where("channels.is_private = ? OR channels.owner_id = ? OR channels.id IN (?)", false, user.ID, memberships)
+	}
+}
+
+// inAccessibleChannels restricts a message query to the channels a user may see
+func inAccessibleChannels(user *models.User) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if user.IsAdmin() {
+			return db
+		}
+		channels := config.DB.Model(&models.Channel{}).Select("channels.id").Scopes(accessibleChannels(user))
+		return db.Where("messages.channel_id IN (?)", channels)
+	}
+}
+
+// loadAccessibleChannel fetches a channel the user may see, writing a 404 response otherwise.
+// Private channels are reported as missing to users who are not members.
+func loadAccessibleChannel(c *gin.Context, user *models.User, channelID uint) (*models.Channel, bool) {
+	var channel models.Channel
+	if err := config.DB.First(&channel, channelID).Error; err != nil || !canAccessChannel(user, &channel) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+		return nil, false
+	}
+	return &channel, true
+}
+
+// AuthorizeSubscription checks that a user may receive a channel's live messages over WebSocket
+func AuthorizeSubscription(userID uint, channelID uint) error {
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		return errors.New("User not found")
+	}
+
+	if sanction, err := activeSanction(userID, 0, models.SanctionBan); err != nil || sanction != nil {
+		return errors.New("Your account is banned")
+	}
+
+	var channel models.Channel
+	if err := config.DB.First(&channel, channelID).Error; err != nil || !canAccessChannel(&user, &channel) {
+		return errors.New("Channel not found")
+	}
+
+	return nil
+}
+
 // CreateChannel handles channel creation
 func CreateChannel(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
 	var channel models.Channel
 	if err := c.ShouldBindJSON(&channel); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := config.DB.Create(&channel).Error; err != nil {
+	// The creator owns the channel
+	ownerID := userID.(uint)
+	channel.OwnerID = &ownerID
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&channel).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, ownerID, auditEntry{
+			Action:     models.AuditChannelCreated,
+			TargetType: models.AuditTargetChannel,
+			TargetID:   channel.ID,
+			After:      channel,
+		})
+	})
+	if err != nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "Channel name already exists"})
 		return
 	}
 
+	emitWebhookEvent(webhooks.EventChannelCreated, channel)
+
 	c.JSON(http.StatusCreated, channel)
 }
 
-// GetChannels returns all channels
+// GetChannels returns all channels the user can access
 func GetChannels(c *gin.Context) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
 	var channels []models.Channel
-	if err := config.DB.Order("created_at desc").Find(&channels).Error; err != nil {
+	if err := config.DB.Scopes(accessibleChannels(user)).Order("created_at desc").Find(&channels).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch channels"})
 		return
 	}
 
-	c.JSON(http.StatusOK, channels)
+	channelIDs := make([]uint, len(channels))
+	for i, channel := range channels {
+		channelIDs[i] = channel.ID
+	}
+
+	states, err := readStates(user, channelIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count unread messages"})
+		return
+	}
+
+	items := make([]ChannelListItem, len(channels))
+	for i, channel := range channels {
+		state := states[channel.ID]
+		items[i] = ChannelListItem{
+			Channel:           channel,
+			LastReadMessageID: state.LastReadMessageID,
+			UnreadCount:       state.UnreadCount,
+			MentionCount:      state.MentionCount,
+		}
+	}
+
+	c.JSON(http.StatusOK, items)
 }
 
 // GetChannel returns a single channel by ID
 func GetChannel(c *gin.Context) {
-	id := c.Param("id")
-	var channel models.Channel
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
 
-	if err := config.DB.First(&channel, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	channel, ok := loadAccessibleChannel(c, user, id)
+	if !ok {
 		return
 	}
 
 	c.JSON(http.StatusOK, channel)
 }
 
+// UpdateChannelRequest represents the channel update request
+type UpdateChannelRequest struct {
+	Name                     *string `json:"name" binding:"omitempty,min=1,max=50"`
+	Description              *string `json:"description"`
+	SlowModeSeconds          *int    `json:"slow_mode_seconds" binding:"omitempty,min=0,max=86400"`
+	DefaultMessageTTLSeconds *int    `json:"default_message_ttl_seconds" binding:"omitempty,min=0,max=2592000"`
+	PostingMode              *string `json:"posting_mode" binding:"omitempty,oneof=all text_only drawing_only"`
+	MaxNbOfLines             *int    `json:"max_nb_of_lines" binding:"omitempty,min=1,max=5"`
+	ModeratorsOnly           *bool   `json:"moderators_only"`
+	IsPrivate                *bool   `json:"is_private"`
+}
+
 // UpdateChannel updates a channel
 func UpdateChannel(c *gin.Context) {
-	id := c.Param("id")
-	var channel models.Channel
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
 
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var channel models.Channel
 	if err := config.DB.First(&channel, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
 		return
 	}
 
-	var updateData models.Channel
-	if err := c.ShouldBindJSON(&updateData); err != nil {
+	var req UpdateChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	// Update only allowed fields
 	updates := map[string]interface{}{}
-	if updateData.Name != "" {
-		updates["name"] = updateData.Name
+	if req.Name != nil && *req.Name != "" {
+		updates["name"] = *req.Name
+	}
+	if req.Description != nil && *req.Description != "" {
+		updates["description"] = *req.Description
+	}
+	if req.SlowModeSeconds != nil {
+		updates["slow_mode_seconds"] = *req.SlowModeSeconds
+	}
+	if req.DefaultMessageTTLSeconds != nil {
+		updates["default_message_ttl_seconds"] = *req.DefaultMessageTTLSeconds
 	}
-	if updateData.Description != "" {
-		updates["description"] = updateData.Description
+	if req.PostingMode != nil && *req.PostingMode != "" {
+		updates["posting_mode"] = *req.PostingMode
+	}
+	if req.MaxNbOfLines != nil {
+		updates["max_nb_of_lines"] = *req.MaxNbOfLines
+	}
+	if req.ModeratorsOnly != nil {
+		updates["moderators_only"] = *req.ModeratorsOnly
+	}
+	if req.IsPrivate != nil {
+		updates["is_private"] = *req.IsPrivate
 	}
 
-	if err := config.DB.Model(&channel).Updates(updates).Error; err != nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Channel name already exists"})
+	if len(updates) > 0 {
+		before := channel
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&channel).Updates(updates).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, c, userID.(uint), auditEntry{
+				Action:     models.AuditChannelUpdated,
+				TargetType: models.AuditTargetChannel,
+				TargetID:   channel.ID,
+				Before:     before,
+				After:      channel,
+			})
+		})
+		if err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Channel name already exists"})
+			return
+		}
+
+		emitWebhookEvent(webhooks.EventChannelUpdated, channel)
+	}
+
+	c.JSON(http.StatusOK, channel)
+}
+
+// UpdateTopicRequest represents the channel topic update request
+type UpdateTopicRequest struct {
+	Topic string `json:"topic" binding:"max=120"`
+}
+
+// UpdateChannelTopic sets the short topic of a channel, an empty topic clears it
+func UpdateChannelTopic(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var channel models.Channel
+	if err := config.DB.First(&channel, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
 		return
 	}
 
+	var req UpdateTopicRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	previousTopic := channel.Topic
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&channel).Update("topic", req.Topic).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, userID.(uint), auditEntry{
+			Action:     models.AuditChannelTopicUpdated,
+			TargetType: models.AuditTargetChannel,
+			TargetID:   channel.ID,
+			Before:     gin.H{"topic": previousTopic},
+			After:      gin.H{"topic": req.Topic},
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update topic"})
+		return
+	}
+
+	if Hub != nil {
+		Hub.BroadcastToChannel(channel.ID, ws.Event{
+			Type:      ws.EventChannelTopicUpdated,
+			ChannelID: channel.ID,
+			Data:      gin.H{"topic": req.Topic},
+		})
+	}
+
 	c.JSON(http.StatusOK, channel)
 }
 
 // DeleteChannel deletes a channel
 func DeleteChannel(c *gin.Context) {
-	id := c.Param("id")
-	var channel models.Channel
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
 
+	var channel models.Channel
 	if err := config.DB.First(&channel, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
 		return
 	}
 
-	if err := config.DB.Delete(&channel).Error; err != nil {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&channel).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, userID.(uint), auditEntry{
+			Action:     models.AuditChannelDeleted,
+			TargetType: models.AuditTargetChannel,
+			TargetID:   channel.ID,
+			Before:     channel,
+		})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete channel"})
 		return
 	}
 
+	emitWebhookEvent(webhooks.EventChannelDeleted, gin.H{"id": channel.ID, "name": channel.Name})
+
 	c.JSON(http.StatusOK, gin.H{"message": "Channel deleted successfully"})
 }
 
-// GetChannelMessages returns all messages for a specific channel
+// GetChannelMessages returns a page of messages of a specific channel, newest first, without thread replies
 func GetChannelMessages(c *gin.Context) {
-	id := c.Param("id")
-	channelID, err := strconv.ParseUint(id, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channel ID"})
+	channelID, ok := parseID(c, "id")
+	if !ok {
 		return
 	}
 
-	// Check if channel exists
-	var channel models.Channel
-	if err := config.DB.First(&channel, channelID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+	user, ok := loadCurrentUser(c)
+	if !ok {
 		return
 	}
 
-	// Get pagination parameters
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
-	offset := (page - 1) * limit
+	// Check if channel exists and is visible to the user
+	if _, ok := loadAccessibleChannel(c, user, channelID); !ok {
+		return
+	}
 
-	var messages []models.Message
-	if err := config.DB.
-		Preload("User").
-		Where("channel_id = ?", channelID).
-		Order("created_at desc").
-		Limit(limit).
-		Offset(offset).
-		Find(&messages).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch messages"})
+	req, ok := parsePageRequest(c)
+	if !ok {
 		return
 	}
 
-	// Convert to response format
-	var responses []models.MessageResponse
-	for _, msg := range messages {
-		responses = append(responses, msg.ToResponse())
+	page, err := paginateMessages(func(db *gorm.DB) *gorm.DB {
+		// Replies are listed in their thread
+		return db.Where("messages.channel_id = ? AND messages.parent_id IS NULL", channelID)
+	}, req, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch messages"})
+		return
 	}
 
-	c.JSON(http.StatusOK, responses)
+	c.JSON(http.StatusOK, page)
 }
@@ -0,0 +1,26 @@
+package handlers
+
+import "pictorial-backend/models"
+
+// decorateMessages fills the parts of message responses stored outside the message itself.
+// Fields depending on the viewer (like "me" on reactions) are computed for viewerID,
+// 0 leaves them unset for payloads broadcast to everyone.
+func decorateMessages(responses []models.MessageResponse, viewerID uint) error {
+	if err := attachReactions(responses, viewerID); err != nil {
+		return err
+	}
+	if err := attachThreads(responses); err != nil {
+		return err
+	}
+	return attachMentions(responses)
+}
+
+// decorateMessage fills a single message response, see decorateMessages
+func decorateMessage(response *models.MessageResponse, viewerID uint) error {
+	responses := []models.MessageResponse{*response}
+	if err := decorateMessages(responses, viewerID); err != nil {
+		return err
+	}
+	*response = responses[0]
+	return nil
+}
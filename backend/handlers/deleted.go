@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"pictorial-backend/config"
+	"pictorial-backend/models"
+	"pictorial-backend/webhooks"
+	ws "pictorial-backend/websocket"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Default and maximum number of deleted messages listed at once
+const (
+	defaultDeletedMessagesLimit = 50
+	maxDeletedMessagesLimit     = 100
+)
+
+// DeletedMessageResponse represents a deleted message shown to moderators
+type DeletedMessageResponse struct {
+	models.MessageResponse
+	DeletedAt time.Time            `json:"deleted_at"`
+	DeletedBy *models.UserResponse `json:"deleted_by"` // Null for messages deleted before deleters were recorded
+}
+
+// DeletedMessagesPage is a page of deleted messages, most recently deleted first
+type DeletedMessagesPage struct {
+	Messages   []DeletedMessageResponse `json:"messages"`
+	HasMore    bool                     `json:"has_more"`
+	NextCursor *uint                    `json:"next_cursor"` // Pass as before to get the next page
+}
+
+// toDeletedResponse converts a soft-deleted message for the moderators
+func toDeletedResponse(message *models.Message) DeletedMessageResponse {
+	response := DeletedMessageResponse{
+		MessageResponse: message.ToResponse(),
+		DeletedAt:       message.DeletedAt.Time,
+	}
+	if message.DeletedBy != nil {
+		deletedBy := message.DeletedBy.ToResponse()
+		response.DeletedBy = &deletedBy
+	}
+	return response
+}
+
+// loadDeletedMessage fetches a soft-deleted message of a channel the moderator can see, writing a 404 response otherwise
+func loadDeletedMessage(c *gin.Context, user *models.User) (*models.Message, bool) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return nil, false
+	}
+
+	var message models.Message
+	if err := config.DB.Unscoped().
+		Preload("User").
+		Preload("DeletedBy").
+		Where("deleted_at IS NOT NULL").
+		First(&message, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deleted message not found"})
+		return nil, false
+	}
+
+	// Messages of deleted channels are out of reach
+	if _, ok := loadAccessibleChannel(c, user, message.ChannelID); !ok {
+		return nil, false
+	}
+
+	return &message, true
+}
+
+// GetDeletedMessages lists the deleted messages of a channel, most recently deleted first (moderator only).
+// Expired messages are not listed.
+func GetDeletedMessages(c *gin.Context) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	channel, ok := loadAccessibleChannel(c, user, id)
+	if !ok {
+		return
+	}
+
+	limit := defaultDeletedMessagesLimit
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		if parsed < maxDeletedMessagesLimit {
+			limit = parsed
+		} else {
+			limit = maxDeletedMessagesLimit
+		}
+	}
+
+	query := config.DB.Unscoped().
+		Preload("User").
+		Preload("DeletedBy").
+		Scopes(notExpired).
+		Where("messages.channel_id = ? AND messages.deleted_at IS NOT NULL", channel.ID)
+
+	// Messages are paged by ID, which follows the deletion order closely enough for review
+	if raw := c.Query("before"); raw != "" {
+		before, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid before"})
+			return
+		}
+		query = query.Where("messages.id < ?", before)
+	}
+
+	var messages []models.Message
+	if err := query.Order("messages.id desc").Limit(limit + 1).Find(&messages).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch deleted messages"})
+		return
+	}
+
+	page := DeletedMessagesPage{Messages: []DeletedMessageResponse{}}
+	if len(messages) > limit {
+		messages = messages[:limit]
+		page.HasMore = true
+		page.NextCursor = &messages[limit-1].ID
+	}
+	for i := range messages {
+		page.Messages = append(page.Messages, toDeletedResponse(&messages[i]))
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// GetDeletedMessageImage returns the drawing of a deleted message (moderator only)
+func GetDeletedMessageImage(c *gin.Context) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	message, ok := loadDeletedMessage(c, user)
+	if !ok {
+		return
+	}
+
+	if !message.HasImage() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message has no image"})
+		return
+	}
+
+	serveDrawing(c, message.ImageKey, message.Image)
+}
+
+// RestoreMessage brings back a deleted message and broadcasts it to the channel again (moderator only)
+func RestoreMessage(c *gin.Context) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	message, ok := loadDeletedMessage(c, user)
+	if !ok {
+		return
+	}
+
+	if message.ExpiresAt != nil && !message.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusGone, gin.H{"error": "Message has expired"})
+		return
+	}
+
+	// A reply is only visible in its thread
+	if message.ParentID != nil {
+		var parent models.Message
+		if err := config.DB.Scopes(notExpired).First(&parent, *message.ParentID).Error; err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "The thread of this reply is deleted, restore it first"})
+			return
+		}
+	}
+
+	before := toDeletedResponse(message)
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Model(&models.Message{}).
+			Where("id = ? AND deleted_at IS NOT NULL", message.ID).
+			UpdateColumns(map[string]interface{}{
+				"deleted_at":    nil,
+				"deleted_by_id": nil,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return recordAudit(tx, c, user.ID, auditEntry{
+			Action:     models.AuditMessageRestored,
+			TargetType: models.AuditTargetMessage,
+			TargetID:   message.ID,
+			Before:     gin.H{"deleted_at": before.DeletedAt, "deleted_by": before.DeletedBy},
+			After:      messageAuditSnapshot(message),
+		})
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deleted message not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore message"})
+		return
+	}
+
+	message.DeletedAt = gorm.DeletedAt{}
+	message.DeletedByID = nil
+	message.DeletedBy = nil
+
+	response := message.ToResponse()
+	broadcast := response
+	decorateMessage(&broadcast, 0)
+
+	event := ws.Event{
+		Type:      ws.EventMessageRestored,
+		ChannelID: message.ChannelID,
+		Data:      broadcast,
+	}
+	if message.User.ShadowBanned {
+		// Only the author sees the messages of a shadow-banned user
+		if Hub != nil {
+			Hub.SendToUser(message.UserID, event)
+		}
+	} else {
+		if Hub != nil {
+			Hub.BroadcastToChannel(message.ChannelID, event)
+		}
+		if message.ParentID != nil {
+			broadcastThreadUpdate(*message.ParentID, message.ChannelID)
+		}
+		emitWebhookEvent(webhooks.EventMessageRestored, broadcast)
+	}
+
+	decorateMessage(&response, user.ID)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// PurgeDeletedMessage deletes a deleted message for good, along with its reactions, mentions and revisions (moderator only)
+func PurgeDeletedMessage(c *gin.Context) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	message, ok := loadDeletedMessage(c, user)
+	if !ok {
+		return
+	}
+
+	// Replies do not outlive their thread, they could no longer be shown or restored
+	var replies []models.Message
+	if err := config.DB.Unscoped().Select("id", "deleted_at").Where("parent_id = ?", message.ID).Find(&replies).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purge message"})
+		return
+	}
+	ids := []uint{message.ID}
+	replyIDs := []uint{}
+	visibleReplyIDs := []uint{}
+	for _, reply := range replies {
+		ids = append(ids, reply.ID)
+		replyIDs = append(replyIDs, reply.ID)
+		if !reply.DeletedAt.Valid {
+			visibleReplyIDs = append(visibleReplyIDs, reply.ID)
+		}
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := hardDeleteMessages(tx, ids...); err != nil {
+			return err
+		}
+		return recordAudit(tx, c, user.ID, auditEntry{
+			Action:     models.AuditMessageHardDeleted,
+			TargetType: models.AuditTargetMessage,
+			TargetID:   message.ID,
+			Before:     messageAuditSnapshot(message),
+			Details:    gin.H{"deleted_at": message.DeletedAt.Time, "deleted_by_id": message.DeletedByID, "reply_ids": replyIDs},
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purge message"})
+		return
+	}
+
+	// Clients still show the replies that were not deleted
+	if len(visibleReplyIDs) > 0 {
+		if Hub != nil {
+			Hub.BroadcastToChannel(message.ChannelID, ws.Event{
+				Type:      ws.EventMessagesBulkDeleted,
+				ChannelID: message.ChannelID,
+				Data:      bulkDeletedMessages{ChannelID: message.ChannelID, MessageIDs: visibleReplyIDs},
+			})
+		}
+		emitWebhookEvent(webhooks.EventMessagesPurged, gin.H{
+			"channel_id":    message.ChannelID,
+			"message_ids":   visibleReplyIDs,
+			"deleted_by_id": user.ID,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Message purged successfully"})
+}
@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"pictorial-backend/config"
+	"pictorial-backend/models"
+	"pictorial-backend/webhooks"
+	ws "pictorial-backend/websocket"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// EditMessageRequest represents the message edit request. Omitted fields are kept,
+// an empty content or image_data removes the text or drawing.
+type EditMessageRequest struct {
+	Content   *string `json:"content"`
+	ImageData *string `json:"image_data"` // Base64 encoded image
+	NbOfLines *int    `json:"nb_of_lines" binding:"omitempty,min=1,max=5"`
+}
+
+// EditMessage replaces the text and/or drawing of a message (author only, within the edit window)
+func EditMessage(c *gin.Context) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var message models.Message
+	if err := config.DB.Preload("Channel").Scopes(notExpired).First(&message, id).Error; err != nil || !canAccessChannel(user, &message.Channel) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+
+	if message.UserID != user.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only edit your own messages"})
+		return
+	}
+
+	// Muted and banned users cannot edit their messages either
+	if sanction, err := activeSanction(user.ID, message.ChannelID, models.SanctionMute, models.SanctionBan); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check sanctions"})
+		return
+	} else if sanction != nil {
+		sanctionError(sanction).respond(c)
+		return
+	}
+
+	if window := config.GetMessageEditWindow(); window > 0 && time.Since(message.CreatedAt) > window {
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Messages can only be edited within %s of posting", window)})
+		return
+	}
+
+	var req EditMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Content == nil && req.ImageData == nil && req.NbOfLines == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to edit"})
+		return
+	}
+
+	// Keep the previous state for the revision history
+	revision := models.MessageRevision{
+		MessageID: message.ID,
+		EditorID:  user.ID,
+		Content:   message.Content,
+		Image:     message.Image,
+		ImageKey:  message.ImageKey,
+		NbOfLines: message.NbOfLines,
+	}
+
+	var flagged []models.FilterRule
+	if req.Content != nil {
+		message.Content = req.Content
+		if *req.Content == "" {
+			message.Content = nil
+		}
+
+		var reqErr *requestError
+		if flagged, reqErr = filterMessageContent(message.ChannelID, message.Content); reqErr != nil {
+			reqErr.respond(c)
+			return
+		}
+		if reqErr := checkLinks(user, message.Content); reqErr != nil {
+			reqErr.respond(c)
+			return
+		}
+	}
+	var imageBytes []byte
+	if req.ImageData != nil {
+		message.Image = nil
+		message.ImageKey = nil
+		if *req.ImageData != "" {
+			var reqErr *requestError
+			if imageBytes, reqErr = decodeDrawing(*req.ImageData); reqErr != nil {
+				reqErr.respond(c)
+				return
+			}
+			key := drawingKey(nil, imageBytes)
+			message.ImageKey = &key
+		}
+	}
+	if req.NbOfLines != nil {
+		message.NbOfLines = *req.NbOfLines
+	}
+
+	hasContent := message.Content != nil && *message.Content != ""
+	hasImage := message.HasImage()
+	if !hasContent && !hasImage {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Message must have at least one of content or image"})
+		return
+	}
+
+	// A new drawing, or fewer lines, must still fit the canvas
+	if hasImage && (req.ImageData != nil || req.NbOfLines != nil) {
+		drawing := imageBytes
+		if drawing == nil {
+			var err error
+			if drawing, err = readDrawing(message.ImageKey, message.Image); err != nil {
+				log.Printf("Failed to read drawing of message %d: %v", message.ID, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read image"})
+				return
+			}
+		}
+		if reqErr := validateDrawing(drawing, message.NbOfLines); reqErr != nil {
+			reqErr.respond(c)
+			return
+		}
+	}
+
+	// The edited message must still fit the channel's settings
+	if reqErr := checkMessageShape(&message.Channel, hasContent, hasImage, message.NbOfLines); reqErr != nil {
+		reqErr.respond(c)
+		return
+	}
+
+	unchanged := equalContent(revision.Content, message.Content) &&
+		drawingKey(revision.ImageKey, revision.Image) == drawingKey(message.ImageKey, message.Image) &&
+		revision.NbOfLines == message.NbOfLines
+
+	if !unchanged {
+		if imageBytes != nil {
+			if _, err := storeDrawing(imageBytes); err != nil {
+				log.Printf("Failed to store drawing: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+				return
+			}
+		}
+
+		now := time.Now()
+		message.EditedAt = &now
+
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&revision).Error; err != nil {
+				return err
+			}
+			return tx.Model(&message).
+				Select("content", "image", "image_key", "nb_of_lines", "edited_at", "updated_at").
+				Updates(&message).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to edit message"})
+			return
+		}
+	}
+
+	config.DB.Preload("User").First(&message, message.ID)
+	response := message.ToResponse()
+
+	if !unchanged {
+		broadcast := response
+		decorateMessage(&broadcast, 0)
+
+		event := ws.Event{
+			Type:      ws.EventMessageEdited,
+			ChannelID: message.ChannelID,
+			Data:      broadcast,
+		}
+		if message.User.ShadowBanned {
+			if Hub != nil {
+				Hub.SendToUser(message.UserID, event)
+			}
+		} else {
+			if Hub != nil {
+				Hub.BroadcastToChannel(message.ChannelID, event)
+			}
+			emitWebhookEvent(webhooks.EventMessageUpdated, broadcast)
+		}
+
+		if len(flagged) > 0 {
+			flagMessage(&message, flagged)
+		}
+	}
+
+	decorateMessage(&response, user.ID)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// equalContent compares two optional message texts
+func equalContent(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// GetMessageRevisions lists the previous versions of a message, oldest first (moderator only)
+func GetMessageRevisions(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	// Deleted messages keep their history
+	var message models.Message
+	if err := config.DB.Unscoped().First(&message, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+
+	var revisions []models.MessageRevision
+	if err := config.DB.Preload("Editor").Where("message_id = ?", message.ID).Order("id").Find(&revisions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch revisions"})
+		return
+	}
+
+	responses := []models.MessageRevisionResponse{}
+	for _, revision := range revisions {
+		responses = append(responses, revision.ToResponse())
+	}
+
+	c.JSON(http.StatusOK, responses)
+}
+
+// GetMessageRevisionImage returns the drawing of a previous version of a message (moderator only)
+func GetMessageRevisionImage(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	revisionID, ok := parseID(c, "revisionId")
+	if !ok {
+		return
+	}
+
+	var revision models.MessageRevision
+	if err := config.DB.Where("message_id = ?", id).First(&revision, revisionID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return
+	}
+
+	if revision.ImageKey == nil && len(revision.Image) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision has no image"})
+		return
+	}
+
+	serveDrawing(c, revision.ImageKey, revision.Image)
+}
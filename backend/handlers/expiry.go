@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"pictorial-backend/config"
+	"pictorial-backend/models"
+	"pictorial-backend/webhooks"
+	ws "pictorial-backend/websocket"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// Longest time-to-live a message can be given
+	maxMessageTTLSeconds = 30 * 24 * 60 * 60
+
+	// Longest time the sweeper sleeps without checking for expired messages
+	sweeperMaxWait = time.Minute
+
+	// Time the sweeper waits after a failed sweep, doubled after each consecutive failure up to sweeperMaxWait
+	sweeperErrorBackoff = 5 * time.Second
+
+	// Number of expired messages deleted per batch
+	sweeperBatchSize = 100
+)
+
+// Wakes the sweeper when a message that expires is posted
+var sweeperWake = make(chan struct{}, 1)
+
+// expiredMessage is the payload of the message expiry event
+type expiredMessage struct {
+	ID        uint  `json:"id"`
+	ChannelID uint  `json:"channel_id"`
+	ParentID  *uint `json:"parent_id,omitempty"`
+}
+
+// notExpired hides messages whose time-to-live has passed, before the sweeper deletes them
+func notExpired(db *gorm.DB) *gorm.DB {
+	return db.Where("(messages.expires_at IS NULL OR messages.expires_at > ?)", time.Now())
+}
+
+// messageTTL returns the time-to-live in seconds of a new message in a channel, 0 if it never expires.
+// The channel default applies when none is requested, and also caps the requested one.
+func messageTTL(channel *models.Channel, requested *int) (int, *requestError) {
+	if requested == nil {
+		return channel.DefaultMessageTTLSeconds, nil
+	}
+
+	if *requested < 1 || *requested > maxMessageTTLSeconds {
+		return 0, &requestError{status: http.StatusBadRequest, body: gin.H{"error": fmt.Sprintf("ttl_seconds must be between 1 and %d", maxMessageTTLSeconds)}}
+	}
+	if channel.DefaultMessageTTLSeconds > 0 && *requested > channel.DefaultMessageTTLSeconds {
+		return 0, &requestError{status: http.StatusBadRequest, body: gin.H{"error": fmt.Sprintf("Messages in this channel expire after at most %d seconds", channel.DefaultMessageTTLSeconds)}}
+	}
+
+	return *requested, nil
+}
+
+// setMessageExpiry makes a message about to be posted expire after ttlSeconds
+func setMessageExpiry(message *models.Message, ttlSeconds int) {
+	if ttlSeconds <= 0 {
+		return
+	}
+	expiresAt := time.Now().Add(time.Duration(ttlSeconds) * time.Second)
+	message.ExpiresAt = &expiresAt
+}
+
+// wakeSweeper makes the sweeper look at the next expiry again without blocking
+func wakeSweeper() {
+	select {
+	case sweeperWake <- struct{}{}:
+	default:
+	}
+}
+
+// RunMessageSweeper hard-deletes messages when their time-to-live passes and tells the channel's
+// clients to remove them. Messages that expired while the server was down are deleted on startup.
+func RunMessageSweeper() {
+	var failures int
+	for {
+		failed := false
+		for {
+			swept, err := sweepExpiredMessages()
+			if err != nil {
+				log.Printf("Message sweeper error: %v", err)
+				failed = true
+				break
+			}
+			if swept < sweeperBatchSize {
+				break
+			}
+		}
+
+		// Back off after a failure, the expired messages would otherwise be retried right away.
+		// Posting messages does not wake the sweeper meanwhile.
+		if failed {
+			backoff := sweeperMaxWait
+			if failures < 4 {
+				backoff = sweeperErrorBackoff << failures
+			}
+			failures++
+			time.Sleep(backoff)
+			continue
+		}
+		failures = 0
+
+		// Sleep until the next message expires, checking at least every sweeperMaxWait
+		wait := sweeperMaxWait
+		var next models.Message
+		if err := config.DB.Unscoped().
+			Select("expires_at").
+			Where("expires_at IS NOT NULL").
+			Order("expires_at").
+			Limit(1).
+			Find(&next).Error; err == nil && next.ExpiresAt != nil {
+			if until := time.Until(*next.ExpiresAt); until < wait {
+				wait = until
+			}
+		}
+		if wait < 0 {
+			wait = 0
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-sweeperWake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// hardDeleteMessages deletes messages for good, along with their reactions, mentions, revisions and idempotency keys.
+// Reports keep their snapshot of the messages.
+func hardDeleteMessages(tx *gorm.DB, ids ...uint) error {
+	for _, dependent := range []interface{}{
+		&models.Reaction{},
+		&models.Mention{},
+		&models.MessageRevision{},
+		&models.IdempotencyKey{},
+	} {
+		if err := tx.Where("message_id IN ?", ids).Delete(dependent).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Unscoped().Where("id IN ?", ids).Delete(&models.Message{}).Error
+}
+
+// sweepExpiredMessages deletes a batch of expired messages along with the replies of expired threads,
+// returning how many messages had expired
+func sweepExpiredMessages() (int, error) {
+	var expired []models.Message
+	if err := config.DB.Unscoped().
+		Select("id", "channel_id", "parent_id", "user_id", "deleted_at").
+		Where("expires_at <= ?", time.Now()).
+		Order("expires_at").
+		Limit(sweeperBatchSize).
+		Find(&expired).Error; err != nil {
+		return 0, err
+	}
+	if len(expired) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]uint, len(expired))
+	expiredIDs := make(map[uint]bool, len(expired))
+	for i, msg := range expired {
+		ids[i] = msg.ID
+		expiredIDs[msg.ID] = true
+	}
+
+	// Replies do not outlive their thread
+	var replies []models.Message
+	if err := config.DB.Unscoped().
+		Select("id", "channel_id", "parent_id", "user_id", "deleted_at").
+		Where("parent_id IN ?", ids).
+		Find(&replies).Error; err != nil {
+		return 0, err
+	}
+	removed := expired
+	for _, reply := range replies {
+		if !expiredIDs[reply.ID] {
+			expiredIDs[reply.ID] = true
+			ids = append(ids, reply.ID)
+			removed = append(removed, reply)
+		}
+	}
+
+	if err := config.DB.Transaction(func(tx *gorm.DB) error {
+		return hardDeleteMessages(tx, ids...)
+	}); err != nil {
+		return 0, err
+	}
+
+	threads := map[uint]uint{}
+	for _, msg := range removed {
+		if Hub != nil {
+			Hub.BroadcastToChannel(msg.ChannelID, ws.Event{
+				Type:      ws.EventMessageExpired,
+				ChannelID: msg.ChannelID,
+				Data:      expiredMessage{ID: msg.ID, ChannelID: msg.ChannelID, ParentID: msg.ParentID},
+			})
+		}
+
+		if !msg.DeletedAt.Valid {
+			emitWebhookEvent(webhooks.EventMessageDeleted, gin.H{
+				"id":         msg.ID,
+				"channel_id": msg.ChannelID,
+				"user_id":    msg.UserID,
+				"expired":    true,
+			})
+		}
+
+		if msg.ParentID != nil && !expiredIDs[*msg.ParentID] {
+			threads[*msg.ParentID] = msg.ChannelID
+		}
+	}
+	for parentID, channelID := range threads {
+		broadcastThreadUpdate(parentID, channelID)
+	}
+
+	return len(expired), nil
+}
@@ -0,0 +1,386 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"pictorial-backend/config"
+	"pictorial-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Number of recent messages compared when looking for duplicates
+const duplicateLookback = 5
+
+// Matches the links counted by the link limit
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+
+// CreateFilterRuleRequest represents the word filter rule creation request
+type CreateFilterRuleRequest struct {
+	ChannelID *uint  `json:"channel_id"` // Server-wide when omitted
+	Pattern   string `json:"pattern" binding:"required,max=200"`
+	IsRegex   bool   `json:"is_regex"`
+	Action    string `json:"action" binding:"required,oneof=block mask flag"`
+	Usernames bool   `json:"usernames"`
+}
+
+// compiledRule is a filter rule ready to be matched
+type compiledRule struct {
+	rule models.FilterRule
+	re   *regexp.Regexp
+}
+
+// compileFilterRule builds the case-insensitive expression of a rule
+func compileFilterRule(rule models.FilterRule) (*compiledRule, error) {
+	pattern := regexp.QuoteMeta(rule.Pattern)
+	if rule.IsRegex {
+		pattern = rule.Pattern
+	}
+
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &compiledRule{rule: rule, re: re}, nil
+}
+
+// isWordChar reports whether a rune is part of a word
+func isWordChar(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+// matches returns the spans of text matched by the rule. Word rules only match whole words.
+func (r *compiledRule) matches(text string) [][]int {
+	var spans [][]int
+	for _, loc := range r.re.FindAllStringIndex(text, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		if !r.rule.IsRegex {
+			before, _ := utf8.DecodeLastRuneInString(text[:loc[0]])
+			after, _ := utf8.DecodeRuneInString(text[loc[1]:])
+			if (loc[0] > 0 && isWordChar(before)) || (loc[1] < len(text) && isWordChar(after)) {
+				continue
+			}
+		}
+		spans = append(spans, loc)
+	}
+	return spans
+}
+
+// loadFilterRules returns the server-wide rules, plus the rules of a channel when channelID is not 0
+func loadFilterRules(channelID uint) ([]*compiledRule, error) {
+	var rules []models.FilterRule
+	if err := config.DB.Where("channel_id IS NULL OR channel_id = ?", channelID).Order("id").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+
+	compiled := make([]*compiledRule, 0, len(rules))
+	for _, rule := range rules {
+		c, err := compileFilterRule(rule)
+		if err != nil {
+			// Rules are validated when created, this only happens if the regexp syntax changed
+			log.Printf("Skipping invalid filter rule %d: %v", rule.ID, err)
+			continue
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
+// filterMessageContent applies the word filter to the text of a message in a channel. Masked words are
+// replaced in place; the rules flagging the message for review are returned.
+func filterMessageContent(channelID uint, content *string) ([]models.FilterRule, *requestError) {
+	if content == nil || *content == "" {
+		return nil, nil
+	}
+
+	rules, err := loadFilterRules(channelID)
+	if err != nil {
+		return nil, &requestError{status: http.StatusInternalServerError, body: gin.H{"error": "Failed to load the word filter"}}
+	}
+
+	// Blocking wins over masking, flags are matched against the original text
+	var flagged []models.FilterRule
+	for _, rule := range rules {
+		if len(rule.matches(*content)) == 0 {
+			continue
+		}
+		switch rule.rule.Action {
+		case models.FilterActionBlock:
+			return nil, &requestError{status: http.StatusBadRequest, body: gin.H{"error": "Message contains blocked words"}}
+		case models.FilterActionFlag:
+			flagged = append(flagged, rule.rule)
+		}
+	}
+
+	masked := *content
+	for _, rule := range rules {
+		if rule.rule.Action != models.FilterActionMask {
+			continue
+		}
+		spans := rule.matches(masked)
+		for i := len(spans) - 1; i >= 0; i-- {
+			start, end := spans[i][0], spans[i][1]
+			masked = masked[:start] + strings.Repeat("*", utf8.RuneCountInString(masked[start:end])) + masked[end:]
+		}
+	}
+	*content = masked
+
+	return flagged, nil
+}
+
+// checkUsername rejects usernames matching a server-wide block rule applied to usernames.
+// Words are matched anywhere in the name, as usernames are often written without spaces.
+// Names are never masked behind their owner's back and there is no message to flag, so other actions do not apply.
+func checkUsername(name string) *requestError {
+	var rules []models.FilterRule
+	if err := config.DB.Where("channel_id IS NULL AND usernames = ? AND action = ?", true, models.FilterActionBlock).Find(&rules).Error; err != nil {
+		return &requestError{status: http.StatusInternalServerError, body: gin.H{"error": "Failed to load the word filter"}}
+	}
+
+	for _, rule := range rules {
+		compiled, err := compileFilterRule(rule)
+		if err != nil {
+			continue
+		}
+		if compiled.re.MatchString(name) {
+			return &requestError{status: http.StatusBadRequest, body: gin.H{"error": "Username is not allowed"}}
+		}
+	}
+	return nil
+}
+
+// checkLinks rejects a text containing more links than allowed. Moderators are not limited.
+func checkLinks(user *models.User, content *string) *requestError {
+	if user.IsModerator() || content == nil {
+		return nil
+	}
+
+	if limit := config.GetMaxLinksPerMessage(); limit >= 0 && len(linkPattern.FindAllStringIndex(*content, limit+1)) > limit {
+		return &requestError{status: http.StatusBadRequest, body: gin.H{"error": fmt.Sprintf("Messages can contain at most %d links", limit)}}
+	}
+	return nil
+}
+
+// checkSpam applies the link limit and rejects a message identical to one the user just posted,
+// drawings being compared by content address. Moderators are not limited.
+func checkSpam(user *models.User, content *string, imageKey string) *requestError {
+	if user.IsModerator() {
+		return nil
+	}
+	if reqErr := checkLinks(user, content); reqErr != nil {
+		return reqErr
+	}
+
+	text := ""
+	if content != nil {
+		text = *content
+	}
+
+	window := config.GetDuplicateMessageWindow()
+	if window == 0 {
+		return nil
+	}
+
+	var recent []models.Message
+	if err := config.DB.
+		Where("user_id = ? AND created_at > ?", user.ID, time.Now().Add(-window)).
+		Order("id desc").
+		Limit(duplicateLookback).
+		Find(&recent).Error; err != nil {
+		return &requestError{status: http.StatusInternalServerError, body: gin.H{"error": "Failed to check for duplicate messages"}}
+	}
+
+	for _, previous := range recent {
+		previousText := ""
+		if previous.Content != nil {
+			previousText = *previous.Content
+		}
+		if previousText == text && drawingKey(previous.ImageKey, previous.Image) == imageKey {
+			return &requestError{status: http.StatusConflict, body: gin.H{"error": "You just posted the same message"}}
+		}
+	}
+	return nil
+}
+
+// flagMessage reports a message matching flag rules to the moderators, as an automatic report without reporter.
+// A message already waiting for review is not reported again.
+func flagMessage(message *models.Message, rules []models.FilterRule) {
+	var pending int64
+	if err := config.DB.Model(&models.MessageReport{}).
+		Where("message_id = ? AND reporter_id IS NULL AND status = ?", message.ID, models.ReportStatusOpen).
+		Count(&pending).Error; err != nil || pending > 0 {
+		return
+	}
+
+	patterns := make([]string, len(rules))
+	for i, rule := range rules {
+		patterns[i] = rule.Pattern
+	}
+
+	report := models.MessageReport{
+		MessageID:        message.ID,
+		Reason:           "Word filter: " + strings.Join(patterns, ", "),
+		ChannelID:        message.ChannelID,
+		AuthorID:         message.UserID,
+		Content:          message.Content,
+		Image:            message.Image,
+		ImageKey:         message.ImageKey,
+		NbOfLines:        message.NbOfLines,
+		MessageCreatedAt: message.CreatedAt,
+		Status:           models.ReportStatusOpen,
+	}
+	if len(report.Reason) > 500 {
+		report.Reason = report.Reason[:500]
+	}
+
+	if err := config.DB.Create(&report).Error; err != nil {
+		log.Printf("Failed to flag message %d: %v", message.ID, err)
+		return
+	}
+
+	notifyModerators(&report)
+}
+
+// GetFilterRules lists the word filter rules (admin only), server-wide ones or a channel's with channel_id
+func GetFilterRules(c *gin.Context) {
+	query := config.DB.Order("id")
+	if raw := c.Query("channel_id"); raw != "" {
+		channelID, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channel_id"})
+			return
+		}
+		query = query.Where("channel_id = ?", channelID)
+	} else {
+		query = query.Where("channel_id IS NULL")
+	}
+
+	rules := []models.FilterRule{}
+	if err := query.Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch filter rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// CreateFilterRule adds a word or regular expression to the word filter (admin only)
+func CreateFilterRule(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req CreateFilterRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule := models.FilterRule{
+		ChannelID:   req.ChannelID,
+		Pattern:     strings.TrimSpace(req.Pattern),
+		IsRegex:     req.IsRegex,
+		Action:      req.Action,
+		Usernames:   req.Usernames,
+		CreatedByID: userID.(uint),
+	}
+
+	if rule.Pattern == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Pattern is required"})
+		return
+	}
+	if _, err := compileFilterRule(rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid regular expression: " + err.Error()})
+		return
+	}
+	if rule.IsRegex && regexp.MustCompile("(?i)"+rule.Pattern).MatchString("") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Regular expression must not match empty text"})
+		return
+	}
+
+	if rule.Usernames && rule.Action != models.FilterActionBlock {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only block rules apply to usernames"})
+		return
+	}
+
+	if rule.ChannelID != nil {
+		if rule.Usernames {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only server-wide rules apply to usernames"})
+			return
+		}
+		var channel models.Channel
+		if err := config.DB.First(&channel, *rule.ChannelID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+			return
+		}
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&rule).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, rule.CreatedByID, auditEntry{
+			Action:     models.AuditFilterRuleCreated,
+			TargetType: models.AuditTargetFilterRule,
+			TargetID:   rule.ID,
+			After:      rule,
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create filter rule"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// DeleteFilterRule removes a rule from the word filter (admin only)
+func DeleteFilterRule(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var rule models.FilterRule
+	if err := config.DB.First(&rule, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Filter rule not found"})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&rule).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, userID.(uint), auditEntry{
+			Action:     models.AuditFilterRuleDeleted,
+			TargetType: models.AuditTargetFilterRule,
+			TargetID:   rule.ID,
+			Before:     rule,
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete filter rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Filter rule deleted successfully"})
+}
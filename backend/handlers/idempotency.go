@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"pictorial-backend/config"
+	"pictorial-backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// replayIdempotentMessage answers with the message, or the scheduled message, already created with an idempotency key, if any.
+// Replays are not broadcast again. It reports whether a response was written.
+func replayIdempotentMessage(c *gin.Context, user *models.User, key string) bool {
+	expiredBefore := time.Now().Add(-config.GetIdempotencyKeyTTL())
+
+	// Forget the user's expired keys so they can be reused
+	config.DB.Where("user_id = ? AND created_at < ?", user.ID, expiredBefore).Delete(&models.IdempotencyKey{})
+
+	var stored models.IdempotencyKey
+	if err := config.DB.Where("user_id = ? AND key = ?", user.ID, key).First(&stored).Error; err != nil {
+		return false
+	}
+
+	if stored.ScheduledMessageID != nil {
+		var scheduled models.ScheduledMessage
+		if err := config.DB.Where("user_id = ?", user.ID).First(&scheduled, *stored.ScheduledMessageID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "The scheduled message created with this idempotency key was deleted"})
+			return true
+		}
+
+		c.Header("Idempotent-Replayed", "true")
+		c.JSON(http.StatusAccepted, scheduled.ToResponse())
+		return true
+	}
+
+	var message models.Message
+	if stored.MessageID == nil || config.DB.Preload("User").Scopes(notExpired).First(&message, *stored.MessageID).Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "The message created with this idempotency key was deleted"})
+		return true
+	}
+
+	response := message.ToResponse()
+	response.Nonce = key
+	if err := decorateMessage(&response, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch message"})
+		return true
+	}
+
+	c.Header("Idempotent-Replayed", "true")
+	c.JSON(http.StatusCreated, response)
+	return true
+}
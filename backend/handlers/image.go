@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image/png"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// Drawings come from the client's 468x170 canvas, split in 5 lines of 34 pixels
+	canvasWidth      = 468
+	canvasLineHeight = 34
+
+	// A full canvas of noise compresses to about 320 KB
+	maxImageBytes = 512 << 10
+)
+
+// Every PNG file starts with this signature
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// imageError describes an image rejected by the validation
+func imageError(status int, message string, details gin.H) *requestError {
+	body := gin.H{"error": message}
+	for key, value := range details {
+		body[key] = value
+	}
+	return &requestError{status: status, body: body}
+}
+
+// decodeImageData decodes a base64 encoded image sent by a client
+func decodeImageData(data string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(data)
+}
+
+// decodeDrawing decodes a base64 encoded drawing sent by a client, without reading more than the size limit
+func decodeDrawing(data string) ([]byte, *requestError) {
+	if base64.StdEncoding.DecodedLen(len(data)) > maxImageBytes+2 {
+		return nil, imageError(http.StatusRequestEntityTooLarge, fmt.Sprintf("Images are limited to %d KB", maxImageBytes>>10), gin.H{"max_bytes": maxImageBytes})
+	}
+
+	imageBytes, err := decodeImageData(data)
+	if err != nil {
+		return nil, imageError(http.StatusBadRequest, "Invalid image data, expected base64", nil)
+	}
+	return imageBytes, nil
+}
+
+// validateDrawing checks that an image is a PNG fitting the canvas lines of a message.
+// The header is checked before decoding, so that the decoded pixels never exceed the canvas.
+func validateDrawing(data []byte, nbOfLines int) *requestError {
+	if len(data) > maxImageBytes {
+		return imageError(http.StatusRequestEntityTooLarge, fmt.Sprintf("Images are limited to %d KB", maxImageBytes>>10), gin.H{"max_bytes": maxImageBytes})
+	}
+	if !bytes.HasPrefix(data, pngSignature) {
+		return imageError(http.StatusUnsupportedMediaType, "Images must be PNG", nil)
+	}
+
+	header, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return imageError(http.StatusBadRequest, "Invalid PNG header: "+err.Error(), nil)
+	}
+
+	maxHeight := nbOfLines * canvasLineHeight
+	if header.Width == 0 || header.Height == 0 {
+		return imageError(http.StatusBadRequest, "Image is empty", nil)
+	}
+	if header.Width > canvasWidth || header.Height > maxHeight {
+		return imageError(http.StatusBadRequest,
+			fmt.Sprintf("Image is %dx%d pixels, at most %dx%d are allowed with nb_of_lines %d", header.Width, header.Height, canvasWidth, maxHeight, nbOfLines),
+			gin.H{"width": header.Width, "height": header.Height, "max_width": canvasWidth, "max_height": maxHeight})
+	}
+
+	// The pixels are bounded by the checked dimensions, whatever the compressed data claims
+	if _, err := png.Decode(bytes.NewReader(data)); err != nil {
+		return imageError(http.StatusBadRequest, "Corrupt PNG data: "+err.Error(), nil)
+	}
+	return nil
+}
+
+// servePNG writes stored image bytes, only labelled as PNG when they are one
+func servePNG(c *gin.Context, data []byte) {
+	c.Header("X-Content-Type-Options", "nosniff")
+	if !bytes.HasPrefix(data, pngSignature) {
+		c.Header("Content-Disposition", "attachment")
+		c.Data(http.StatusOK, "application/octet-stream", data)
+		return
+	}
+	c.Data(http.StatusOK, "image/png", data)
+}
@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"pictorial-backend/config"
+	"pictorial-backend/models"
+	"pictorial-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Invites expire after a week unless told otherwise
+const defaultInviteLifetime = 7 * 24 * time.Hour
+
+// CreateInviteRequest represents the invite creation request
+type CreateInviteRequest struct {
+	ExpiresIn *int `json:"expires_in" binding:"omitempty,min=0,max=2592000"` // Seconds, 0 means never
+	MaxUses   int  `json:"max_uses" binding:"min=0,max=10000"`               // 0 means unlimited
+}
+
+// InvitePreview represents the public information shown for an invite code
+type InvitePreview struct {
+	Code        string     `json:"code"`
+	ChannelID   uint       `json:"channel_id"`
+	ChannelName string     `json:"channel_name"`
+	Description string     `json:"description"`
+	IsPrivate   bool       `json:"is_private"`
+	MemberCount int64      `json:"member_count"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+// CreateInvite mints a new invite code for a channel (admins and channel owner)
+func CreateInvite(c *gin.Context) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var channel models.Channel
+	if err := config.DB.First(&channel, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+		return
+	}
+
+	if !canManageChannel(user, &channel) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins and the channel owner can create invites"})
+		return
+	}
+
+	// The body is optional, defaults apply when it is empty
+	var req CreateInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	code, err := utils.RandomToken(12)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate invite code"})
+		return
+	}
+
+	invite := models.ChannelInvite{
+		Code:      code,
+		ChannelID: channel.ID,
+		CreatorID: user.ID,
+		MaxUses:   req.MaxUses,
+	}
+
+	lifetime := defaultInviteLifetime
+	if req.ExpiresIn != nil {
+		lifetime = time.Duration(*req.ExpiresIn) * time.Second
+	}
+	if lifetime > 0 {
+		expiresAt := time.Now().Add(lifetime)
+		invite.ExpiresAt = &expiresAt
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&invite).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, user.ID, auditEntry{
+			Action:     models.AuditInviteCreated,
+			TargetType: models.AuditTargetInvite,
+			TargetID:   invite.ID,
+			After:      invite,
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, invite)
+}
+
+// GetChannelInvites lists the active invites of a channel (admins and channel owner)
+func GetChannelInvites(c *gin.Context) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var channel models.Channel
+	if err := config.DB.First(&channel, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+		return
+	}
+
+	if !canManageChannel(user, &channel) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins and the channel owner can list invites"})
+		return
+	}
+
+	var invites []models.ChannelInvite
+	if err := config.DB.Where("channel_id = ?", channel.ID).Order("created_at desc").Find(&invites).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invites"})
+		return
+	}
+
+	c.JSON(http.StatusOK, invites)
+}
+
+// RevokeInvite deletes an invite code (admins and channel owner)
+func RevokeInvite(c *gin.Context) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	var invite models.ChannelInvite
+	if err := config.DB.Preload("Channel").Where("code = ?", c.Param("code")).First(&invite).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+		return
+	}
+
+	if !canManageChannel(user, &invite.Channel) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins and the channel owner can revoke invites"})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&invite).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, user.ID, auditEntry{
+			Action:     models.AuditInviteRevoked,
+			TargetType: models.AuditTargetInvite,
+			TargetID:   invite.ID,
+			Before:     invite,
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invite"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invite revoked successfully"})
+}
+
+// GetInvitePreview returns public information about an invite (no authentication required)
+func GetInvitePreview(c *gin.Context) {
+	var invite models.ChannelInvite
+	if err := config.DB.Preload("Channel").Where("code = ?", c.Param("code")).First(&invite).Error; err != nil || invite.Channel.ID == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+		return
+	}
+
+	if !invite.IsUsable() {
+		c.JSON(http.StatusGone, gin.H{"error": "Invite has expired"})
+		return
+	}
+
+	memberCount, err := countChannelMembers(&invite.Channel)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count members"})
+		return
+	}
+
+	c.JSON(http.StatusOK, InvitePreview{
+		Code:        invite.Code,
+		ChannelID:   invite.Channel.ID,
+		ChannelName: invite.Channel.Name,
+		Description: invite.Channel.Description,
+		IsPrivate:   invite.Channel.IsPrivate,
+		MemberCount: memberCount,
+		ExpiresAt:   invite.ExpiresAt,
+	})
+}
+
+// countChannelMembers counts the members of a channel, its owner included. Everyone can read
+// a public channel, so its members are the users who joined it, posted in it or opened it.
+func countChannelMembers(channel *models.Channel) (int64, error) {
+	var ownerID uint
+	if channel.OwnerID != nil {
+		ownerID = *channel.OwnerID
+	}
+
+	members := config.DB.Model(&models.ChannelMember{}).Select("user_id").Where("channel_id = ?", channel.ID)
+	query := config.DB.Model(&models.User{})
+	if channel.IsPrivate {
+		query = query.Where("(users.id IN (?) OR users.id = ?)", members, ownerID)
+	} else {
+		posters := config.DB.Model(&models.Message{}).Select("user_id").Where("channel_id = ?", channel.ID)
+		readers := config.DB.Model(&models.ChannelReadState{}).Select("user_id").Where("channel_id = ?", channel.ID)
+		query = query.Where("(users.id IN (?) OR users.id IN (?) OR users.id IN (?) OR users.id = ?)", members, posters, readers, ownerID)
+	}
+
+	var count int64
+	err := query.Count(&count).Error
+	return count, err
+}
+
+// errInviteUnusable is returned when an invite expired or ran out of uses while accepting it
+var errInviteUnusable = errors.New("invite unusable")
+
+// AcceptInvite joins the current user to the invite's channel
+func AcceptInvite(c *gin.Context) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	var channel models.Channel
+	alreadyMember := false
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the invite so concurrent accepts cannot exceed max uses
+		var invite models.ChannelInvite
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("code = ?", c.Param("code")).
+			First(&invite).Error; err != nil {
+			return err
+		}
+
+		if err := tx.First(&channel, invite.ChannelID).Error; err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.ChannelMember{}).
+			Where("channel_id = ? AND user_id = ?", channel.ID, user.ID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			// Joining again does not consume a use
+			alreadyMember = true
+			return nil
+		}
+
+		if !invite.IsUsable() {
+			return errInviteUnusable
+		}
+
+		if err := tx.Create(&models.ChannelMember{ChannelID: channel.ID, UserID: user.ID}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&invite).UpdateColumn("uses", gorm.Expr("uses + 1")).Error
+	})
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+		return
+	case errors.Is(err, errInviteUnusable):
+		c.JSON(http.StatusGone, gin.H{"error": "Invite has expired"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept invite"})
+		return
+	}
+
+	if alreadyMember {
+		c.JSON(http.StatusOK, channel)
+		return
+	}
+
+	c.JSON(http.StatusCreated, channel)
+}
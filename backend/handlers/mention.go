@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"pictorial-backend/config"
+	"pictorial-backend/models"
+	ws "pictorial-backend/websocket"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Maximum number of users notified by a single message
+const maxMentionsPerMessage = 20
+
+// An @ starting a word, followed by the username up to the next space
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([^\s@]+)`)
+
+// parseMentions extracts the distinct usernames mentioned in a message text, lowercased
+func parseMentions(content string) []string {
+	seen := make(map[string]bool)
+	var names []string
+
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		// "@alice," or "(@alice)" mention alice
+		name := strings.ToLower(strings.TrimRight(match[1], ".,;:!?)]}'\""))
+		if name == "" || len(name) > 50 || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+
+		if len(names) == maxMentionsPerMessage {
+			break
+		}
+	}
+	return names
+}
+
+// recordMentions stores the mentions of a new message and returns the mentioned user IDs.
+// Only users who can read the channel are mentioned, authors do not mention themselves.
+func recordMentions(message *models.Message) []uint {
+	if message.Content == nil {
+		return nil
+	}
+
+	names := parseMentions(*message.Content)
+	if len(names) == 0 {
+		return nil
+	}
+
+	var channel models.Channel
+	if err := config.DB.First(&channel, message.ChannelID).Error; err != nil {
+		log.Printf("Failed to load channel %d for mentions: %v", message.ChannelID, err)
+		return nil
+	}
+
+	var users []models.User
+	if err := config.DB.Where("LOWER(name) IN ?", names).Order("id").Find(&users).Error; err != nil {
+		log.Printf("Failed to resolve mentions of message %d: %v", message.ID, err)
+		return nil
+	}
+
+	var mentions []models.Mention
+	for i := range users {
+		if users[i].ID == message.UserID || !canAccessChannel(&users[i], &channel) {
+			continue
+		}
+		mentions = append(mentions, models.Mention{
+			MessageID: message.ID,
+			UserID:    users[i].ID,
+			ChannelID: message.ChannelID,
+		})
+	}
+	if len(mentions) == 0 {
+		return nil
+	}
+
+	if err := config.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&mentions).Error; err != nil {
+		log.Printf("Failed to record mentions of message %d: %v", message.ID, err)
+		return nil
+	}
+
+	ids := make([]uint, len(mentions))
+	for i, mention := range mentions {
+		ids[i] = mention.UserID
+	}
+	return ids
+}
+
+// notifyMentions pushes a mention event to every connection of the mentioned users
+func notifyMentions(response models.MessageResponse) {
+	if Hub == nil {
+		return
+	}
+	for _, userID := range response.MentionIDs {
+		Hub.SendToUser(userID, ws.Event{
+			Type:      ws.EventMention,
+			ChannelID: response.ChannelID,
+			Data:      response,
+		})
+	}
+}
+
+// attachMentions fills the mentioned user IDs of message responses with a single query
+func attachMentions(responses []models.MessageResponse) error {
+	if len(responses) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(responses))
+	for i := range responses {
+		ids[i] = responses[i].ID
+	}
+
+	var mentions []models.Mention
+	if err := config.DB.Select("message_id", "user_id").Where("message_id IN ?", ids).Order("id").Find(&mentions).Error; err != nil {
+		return err
+	}
+
+	byMessage := make(map[uint][]uint, len(mentions))
+	for _, mention := range mentions {
+		byMessage[mention.MessageID] = append(byMessage[mention.MessageID], mention.UserID)
+	}
+
+	for i := range responses {
+		if userIDs, ok := byMessage[responses[i].ID]; ok {
+			responses[i].MentionIDs = userIDs
+		}
+	}
+	return nil
+}
+
+// GetMentions returns a page of the messages mentioning the current user, newest first
+func GetMentions(c *gin.Context) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	req, ok := parsePageRequest(c)
+	if !ok {
+		return
+	}
+
+	mentioned := config.DB.Model(&models.Mention{}).Select("message_id").Where("user_id = ?", user.ID)
+	page, err := paginateMessages(func(db *gorm.DB) *gorm.DB {
+		// Channels the user lost access to are left out
+		return inAccessibleChannels(user)(db).Where("messages.id IN (?)", mentioned)
+	}, req, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch mentions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
@@ -1,15 +1,21 @@
 package handlers
 
 import (
-	"encoding/base64"
+	"fmt"
+	"log"
+	"math"
 	"net/http"
 	"strconv"
+	"time"
 
 	"pictorial-backend/config"
 	"pictorial-backend/models"
+	"pictorial-backend/webhooks"
 	ws "pictorial-backend/websocket"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // WebSocket hub for broadcasting messages
@@ -17,10 +23,14 @@ var Hub *ws.Hub
 
 // CreateMessageRequest represents the message creation request
 type CreateMessageRequest struct {
-	ChannelID uint    `json:"channel_id" binding:"required"`
-	Content   *string `json:"content"`
-	ImageData *string `json:"image_data"` // Base64 encoded image
-	NbOfLines int     `json:"nb_of_lines" binding:"required,min=1,max=5"`
+	ChannelID  uint       `json:"channel_id" binding:"required"`
+	ParentID   *uint      `json:"parent_id"`               // Message to reply to, in the same channel
+	Nonce      string     `json:"nonce" binding:"max=100"` // Client-generated key, same as the Idempotency-Key header
+	Content    *string    `json:"content"`
+	ImageData  *string    `json:"image_data"` // Base64 encoded image
+	NbOfLines  int        `json:"nb_of_lines" binding:"required,min=1,max=5"`
+	SendAt     *time.Time `json:"send_at"`     // Schedules the message instead of posting it now
+	TTLSeconds *int       `json:"ttl_seconds"` // Deletes the message this long after it is posted
 }
 
 // CreateMessage handles message creation
@@ -43,10 +53,55 @@ func CreateMessage(c *gin.Context) {
 		return
 	}
 
-	// Check if channel exists
-	var channel models.Channel
-	if err := config.DB.First(&channel, req.ChannelID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return
+	}
+
+	// Muted and banned users cannot post
+	if sanction, err := activeSanction(user.ID, req.ChannelID, models.SanctionMute, models.SanctionBan); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check sanctions"})
+		return
+	} else if sanction != nil {
+		sanctionError(sanction).respond(c)
+		return
+	}
+
+	// A retried request returns the message created the first time
+	idempotencyKey := c.GetHeader("Idempotency-Key")
+	if idempotencyKey == "" {
+		idempotencyKey = req.Nonce
+	}
+	if len(idempotencyKey) > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency key is limited to 100 characters"})
+		return
+	}
+	if idempotencyKey != "" && replayIdempotentMessage(c, &user, idempotencyKey) {
+		return
+	}
+
+	// Check if channel exists and is visible to the user
+	channel, ok := loadAccessibleChannel(c, &user, req.ChannelID)
+	if !ok {
+		return
+	}
+
+	// Enforce the channel's posting settings, slow mode only applies to messages posted now
+	hasContent := req.Content != nil && *req.Content != ""
+	hasImage := req.ImageData != nil && *req.ImageData != ""
+	check := checkPostingRestrictions
+	if req.SendAt != nil {
+		check = checkChannelRules
+	}
+	if reqErr := check(channel, &user, hasContent, hasImage, req.NbOfLines); reqErr != nil {
+		reqErr.respond(c)
+		return
+	}
+
+	ttl, reqErr := messageTTL(channel, req.TTLSeconds)
+	if reqErr != nil {
+		reqErr.respond(c)
 		return
 	}
 
@@ -57,69 +112,293 @@ func CreateMessage(c *gin.Context) {
 		NbOfLines: req.NbOfLines,
 	}
 
-	// Decode base64 image if provided
+	if req.ParentID != nil {
+		parent, ok := resolveThreadParent(c, &user, *req.ParentID, channel.ID)
+		if !ok {
+			return
+		}
+		message.ParentID = &parent.ID
+	}
+
+	// Decode base64 image if provided, it must be a PNG fitting the message's lines
+	var imageBytes []byte
 	if req.ImageData != nil && *req.ImageData != "" {
-		imageBytes, err := base64.StdEncoding.DecodeString(*req.ImageData)
+		var reqErr *requestError
+		if imageBytes, reqErr = decodeDrawing(*req.ImageData); reqErr != nil {
+			reqErr.respond(c)
+			return
+		}
+		if reqErr := validateDrawing(imageBytes, req.NbOfLines); reqErr != nil {
+			reqErr.respond(c)
+			return
+		}
+	}
+
+	// The word filter may mask the text, the spam checks then compare what would be posted
+	flagged, reqErr := filterMessageContent(channel.ID, message.Content)
+	if reqErr != nil {
+		reqErr.respond(c)
+		return
+	}
+	if reqErr := checkSpam(&user, message.Content, drawingKey(nil, imageBytes)); reqErr != nil {
+		reqErr.respond(c)
+		return
+	}
+
+	// The drawing is only stored once the message is accepted
+	if imageBytes != nil {
+		key, err := storeDrawing(imageBytes)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image data"})
+			log.Printf("Failed to store drawing: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
 			return
 		}
-		message.Image = imageBytes
+		message.ImageKey = &key
 	}
 
-	if err := config.DB.Create(&message).Error; err != nil {
+	if req.SendAt != nil {
+		scheduleMessage(c, &user, &message, *req.SendAt, req.TTLSeconds, idempotencyKey)
+		return
+	}
+
+	setMessageExpiry(&message, ttl)
+
+	var rememberKey func(tx *gorm.DB) error
+	if idempotencyKey != "" {
+		rememberKey = func(tx *gorm.DB) error {
+			return tx.Create(&models.IdempotencyKey{UserID: user.ID, Key: idempotencyKey, MessageID: &message.ID}).Error
+		}
+	}
+
+	response, err := publishMessageTx(&message, idempotencyKey, rememberKey)
+	if err != nil {
+		// A concurrent request with the same key won the race
+		if idempotencyKey != "" && replayIdempotentMessage(c, &user, idempotencyKey) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create message"})
 		return
 	}
 
+	if len(flagged) > 0 {
+		flagMessage(&message, flagged)
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// publishMessage stores a new message and broadcasts it to the channel's WebSocket clients
+func publishMessage(message *models.Message) (models.MessageResponse, error) {
+	return publishMessageTx(message, "", nil)
+}
+
+// publishMessageTx stores a new message along with the writes of extra in a single transaction,
+// then broadcasts it. The nonce is echoed in the response and broadcast so the sender can
+// reconcile the message it displayed optimistically.
+func publishMessageTx(message *models.Message, nonce string, extra func(tx *gorm.DB) error) (models.MessageResponse, error) {
+	if err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(message).Error; err != nil {
+			return err
+		}
+		if err := recordLastPost(tx, message); err != nil {
+			return err
+		}
+		if extra != nil {
+			return extra(tx)
+		}
+		return nil
+	}); err != nil {
+		return models.MessageResponse{}, err
+	}
+
+	if message.ExpiresAt != nil {
+		wakeSweeper()
+	}
+
 	// Load user data
-	config.DB.Preload("User").First(&message, message.ID)
+	config.DB.Preload("User").First(message, message.ID)
 
 	response := message.ToResponse()
+	response.Nonce = nonce
+
+	// Messages of shadow-banned users only go back to their own connections, without mentioning anyone
+	if message.User.ShadowBanned {
+		if Hub != nil {
+			Hub.SendToUser(message.UserID, response)
+		}
+		return response, nil
+	}
+
+	if mentionIDs := recordMentions(message); mentionIDs != nil {
+		response.MentionIDs = mentionIDs
+	}
 
 	// Broadcast message to WebSocket clients in the channel
 	if Hub != nil {
-		Hub.BroadcastToChannel(req.ChannelID, response)
+		Hub.BroadcastToChannel(message.ChannelID, response)
+	}
+	notifyMentions(response)
+	if message.ParentID != nil {
+		broadcastThreadUpdate(*message.ParentID, message.ChannelID)
 	}
 
-	c.JSON(http.StatusCreated, response)
+	emitWebhookEvent(webhooks.EventMessageCreated, response)
+
+	return response, nil
+}
+
+// requestError carries the HTTP status and JSON body of a rejected request
+type requestError struct {
+	status  int
+	body    gin.H
+	headers map[string]string
+}
+
+// respond writes the error to the client
+func (e *requestError) respond(c *gin.Context) {
+	for key, value := range e.headers {
+		c.Header(key, value)
+	}
+	c.JSON(e.status, e.body)
+}
+
+// recordLastPost remembers when the author last posted in the message's channel, for slow mode
+func recordLastPost(tx *gorm.DB, message *models.Message) error {
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "channel_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"posted_at": gorm.Expr("GREATEST(channel_last_posts.posted_at, excluded.posted_at)")}),
+	}).Create(&models.ChannelLastPost{UserID: message.UserID, ChannelID: message.ChannelID, PostedAt: message.CreatedAt}).Error
+}
+
+// checkPostingRestrictions verifies that a user may post the described message in a channel now
+func checkPostingRestrictions(channel *models.Channel, user *models.User, hasContent, hasImage bool, nbOfLines int) *requestError {
+	if reqErr := checkChannelRules(channel, user, hasContent, hasImage, nbOfLines); reqErr != nil {
+		return reqErr
+	}
+
+	// Moderators are not subject to slow mode. The last post is tracked apart from the messages,
+	// so deleting a message or giving it a short time-to-live does not reset the wait.
+	if channel.SlowModeSeconds > 0 && !user.IsModerator() {
+		var last models.ChannelLastPost
+		err := config.DB.
+			Where("channel_id = ? AND user_id = ?", channel.ID, user.ID).
+			Limit(1).
+			Find(&last).Error
+		if err != nil {
+			return &requestError{status: http.StatusInternalServerError, body: gin.H{"error": "Failed to check slow mode"}}
+		}
+
+		if !last.PostedAt.IsZero() {
+			nextPostAt := last.PostedAt.Add(time.Duration(channel.SlowModeSeconds) * time.Second)
+			if wait := time.Until(nextPostAt); wait > 0 {
+				retryAfter := int(math.Ceil(wait.Seconds()))
+				return &requestError{
+					status: http.StatusTooManyRequests,
+					body: gin.H{
+						"error":        fmt.Sprintf("Slow mode is enabled: you can post again in %d seconds", retryAfter),
+						"retry_after":  retryAfter,
+						"next_post_at": nextPostAt.UTC(),
+					},
+					headers: map[string]string{"Retry-After": strconv.Itoa(retryAfter)},
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkChannelRules verifies that a user may post the described message in a channel, slow mode aside
+func checkChannelRules(channel *models.Channel, user *models.User, hasContent, hasImage bool, nbOfLines int) *requestError {
+	if channel.ModeratorsOnly && !user.IsModerator() {
+		return &requestError{status: http.StatusForbidden, body: gin.H{"error": "Only moderators may post in this channel"}}
+	}
+
+	return checkMessageShape(channel, hasContent, hasImage, nbOfLines)
+}
+
+// checkMessageShape verifies that a message matches the channel's posting mode and line limit
+func checkMessageShape(channel *models.Channel, hasContent, hasImage bool, nbOfLines int) *requestError {
+	switch channel.PostingMode {
+	case models.PostingModeTextOnly:
+		if hasImage {
+			return &requestError{status: http.StatusBadRequest, body: gin.H{"error": "This channel only accepts text messages"}}
+		}
+	case models.PostingModeDrawingOnly:
+		if !hasImage || hasContent {
+			return &requestError{status: http.StatusBadRequest, body: gin.H{"error": "This channel only accepts drawings"}}
+		}
+	}
+
+	if channel.MaxNbOfLines > 0 && nbOfLines > channel.MaxNbOfLines {
+		return &requestError{status: http.StatusBadRequest, body: gin.H{
+			"error": fmt.Sprintf("This channel allows at most %d lines per message", channel.MaxNbOfLines),
+		}}
+	}
+
+	return nil
 }
 
 // GetMessage returns a single message by ID
 func GetMessage(c *gin.Context) {
-	id := c.Param("id")
-	var message models.Message
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
 
-	if err := config.DB.Preload("User").Preload("Channel").First(&message, id).Error; err != nil {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var message models.Message
+	if err := config.DB.Preload("User").Preload("Channel").Scopes(notExpired, visibleTo(user)).First(&message, id).Error; err != nil || !canAccessChannel(user, &message.Channel) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, message.ToResponse())
+	response := message.ToResponse()
+	if err := decorateMessage(&response, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch message"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // GetMessageImage returns the image data for a message
 func GetMessageImage(c *gin.Context) {
-	id := c.Param("id")
-	var message models.Message
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
 
-	if err := config.DB.First(&message, id).Error; err != nil {
+	var message models.Message
+	if err := config.DB.Preload("Channel").Scopes(notExpired, visibleTo(user)).First(&message, id).Error; err != nil || !canAccessChannel(user, &message.Channel) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
 		return
 	}
 
-	if len(message.Image) == 0 {
+	if !message.HasImage() {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Message has no image"})
 		return
 	}
 
-	// Return the image as PNG
-	c.Data(http.StatusOK, "image/png", message.Image)
+	serveDrawing(c, message.ImageKey, message.Image)
 }
 
 // DeleteMessage deletes a message
 func DeleteMessage(c *gin.Context) {
-	id := c.Param("id")
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
 	userID, exists := c.Get("userID")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
@@ -127,7 +406,7 @@ func DeleteMessage(c *gin.Context) {
 	}
 
 	var message models.Message
-	if err := config.DB.First(&message, id).Error; err != nil {
+	if err := config.DB.Scopes(notExpired).First(&message, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
 		return
 	}
@@ -145,36 +424,72 @@ func DeleteMessage(c *gin.Context) {
 		return
 	}
 
-	if err := config.DB.Delete(&message).Error; err != nil {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := softDeleteMessages(tx, user.ID, message.ID); err != nil {
+			return err
+		}
+		// Authors deleting their own messages are not audited
+		if message.UserID == user.ID {
+			return nil
+		}
+		return recordAudit(tx, c, user.ID, auditEntry{
+			Action:     models.AuditMessageDeleted,
+			TargetType: models.AuditTargetMessage,
+			TargetID:   message.ID,
+			Before:     messageAuditSnapshot(&message),
+		})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete message"})
 		return
 	}
 
+	messageDeleted(&message, user.ID)
+
 	c.JSON(http.StatusOK, gin.H{"message": "Message deleted successfully"})
 }
 
-// GetMessages returns all messages with pagination
+// softDeleteMessages hides messages, remembering who deleted them so moderators can review and restore them
+func softDeleteMessages(tx *gorm.DB, deletedByID uint, ids ...uint) error {
+	return tx.Model(&models.Message{}).
+		Where("id IN ?", ids).
+		UpdateColumns(map[string]interface{}{
+			"deleted_at":    time.Now(),
+			"deleted_by_id": deletedByID,
+		}).Error
+}
+
+// messageDeleted updates the thread counters and notifies webhooks after a message was deleted
+func messageDeleted(message *models.Message, deletedByID uint) {
+	if message.ParentID != nil {
+		broadcastThreadUpdate(*message.ParentID, message.ChannelID)
+	}
+
+	emitWebhookEvent(webhooks.EventMessageDeleted, gin.H{
+		"id":            message.ID,
+		"channel_id":    message.ChannelID,
+		"user_id":       message.UserID,
+		"deleted_by_id": deletedByID,
+	})
+}
+
+// GetMessages returns a page of messages from the channels the user can access, newest first
 func GetMessages(c *gin.Context) {
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
-	offset := (page - 1) * limit
-
-	var messages []models.Message
-	if err := config.DB.
-		Preload("User").
-		Preload("Channel").
-		Order("created_at desc").
-		Limit(limit).
-		Offset(offset).
-		Find(&messages).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch messages"})
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	req, ok := parsePageRequest(c)
+	if !ok {
 		return
 	}
 
-	var responses []models.MessageResponse
-	for _, msg := range messages {
-		responses = append(responses, msg.ToResponse())
+	page, err := paginateMessages(inAccessibleChannels(user), req, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch messages"})
+		return
 	}
 
-	c.JSON(http.StatusOK, responses)
+	c.JSON(http.StatusOK, page)
 }
@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"pictorial-backend/config"
+	"pictorial-backend/models"
+	"pictorial-backend/utils"
+	"pictorial-backend/webhooks"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Dispatcher delivering events to outgoing webhooks
+var Webhooks *webhooks.Dispatcher
+
+// Number of deliveries listed per webhook
+const deliveriesLimit = 50
+
+// OutgoingWebhookRequest represents the outgoing webhook creation and update request
+type OutgoingWebhookRequest struct {
+	URL    string   `json:"url" binding:"required,max=2048"`
+	Events []string `json:"events" binding:"required,min=1"`
+	Active *bool    `json:"active"`
+}
+
+// emitWebhookEvent queues an event for the outgoing webhooks subscribed to it
+func emitWebhookEvent(eventType string, data interface{}) {
+	if Webhooks == nil {
+		return
+	}
+	if err := Webhooks.Enqueue(eventType, data); err != nil {
+		log.Printf("Failed to queue webhook event %s: %v", eventType, err)
+	}
+}
+
+// validateOutgoingWebhook checks the target URL and event types of a request
+func validateOutgoingWebhook(req *OutgoingWebhookRequest) string {
+	target, err := url.ParseRequestURI(req.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return "URL must be an absolute http or https URL"
+	}
+
+	for _, event := range req.Events {
+		if !webhooks.IsEventType(event) {
+			return "Unknown event type: " + event + " (expected one of " + strings.Join(webhooks.EventTypes, ", ") + ")"
+		}
+	}
+
+	return ""
+}
+
+// CreateOutgoingWebhook registers an outgoing webhook (admin only)
+func CreateOutgoingWebhook(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req OutgoingWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if msg := validateOutgoingWebhook(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	secret, err := utils.RandomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate webhook secret"})
+		return
+	}
+
+	hook := models.OutgoingWebhook{
+		URL:       req.URL,
+		Secret:    secret,
+		Events:    strings.Join(req.Events, ","),
+		Active:    req.Active == nil || *req.Active,
+		CreatorID: userID.(uint),
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&hook).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, hook.CreatorID, auditEntry{
+			Action:     models.AuditOutgoingWebhookCreated,
+			TargetType: models.AuditTargetOutgoingWebhook,
+			TargetID:   hook.ID,
+			After:      hook.ToResponse(),
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	// The secret is only ever shown here
+	response := hook.ToResponse()
+	response.Secret = secret
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetOutgoingWebhooks lists the outgoing webhooks (admin only)
+func GetOutgoingWebhooks(c *gin.Context) {
+	var hooks []models.OutgoingWebhook
+	if err := config.DB.Order("created_at desc").Find(&hooks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch webhooks"})
+		return
+	}
+
+	responses := []models.OutgoingWebhookResponse{}
+	for _, hook := range hooks {
+		responses = append(responses, hook.ToResponse())
+	}
+
+	c.JSON(http.StatusOK, responses)
+}
+
+// UpdateOutgoingWebhook changes the URL, events or active state of an outgoing webhook (admin only)
+func UpdateOutgoingWebhook(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var hook models.OutgoingWebhook
+	if err := config.DB.First(&hook, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	var req OutgoingWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if msg := validateOutgoingWebhook(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	updates := map[string]interface{}{
+		"url":    req.URL,
+		"events": strings.Join(req.Events, ","),
+	}
+	if req.Active != nil {
+		updates["active"] = *req.Active
+	}
+
+	before := hook.ToResponse()
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&hook).Updates(updates).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, userID.(uint), auditEntry{
+			Action:     models.AuditOutgoingWebhookUpdated,
+			TargetType: models.AuditTargetOutgoingWebhook,
+			TargetID:   hook.ID,
+			Before:     before,
+			After:      hook.ToResponse(),
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, hook.ToResponse())
+}
+
+// DeleteOutgoingWebhook deletes an outgoing webhook, pending deliveries are dropped (admin only)
+func DeleteOutgoingWebhook(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var hook models.OutgoingWebhook
+	if err := config.DB.First(&hook, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&hook).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, userID.(uint), auditEntry{
+			Action:     models.AuditOutgoingWebhookDeleted,
+			TargetType: models.AuditTargetOutgoingWebhook,
+			TargetID:   hook.ID,
+			Before:     hook.ToResponse(),
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// PingOutgoingWebhook queues a ping event to test an outgoing webhook (admin only)
+func PingOutgoingWebhook(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var hook models.OutgoingWebhook
+	if err := config.DB.First(&hook, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	if !hook.Active {
+		c.JSON(http.StatusConflict, gin.H{"error": "Webhook is inactive"})
+		return
+	}
+
+	if Webhooks == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Webhook delivery is not running"})
+		return
+	}
+
+	delivery, err := Webhooks.Ping(&hook)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue ping"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
+
+// GetWebhookDeliveries lists the most recent deliveries of an outgoing webhook (admin only)
+func GetWebhookDeliveries(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var hook models.OutgoingWebhook
+	if err := config.DB.Unscoped().First(&hook, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	query := config.DB.Where("webhook_id = ?", hook.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var deliveries []models.WebhookDelivery
+	if err := query.Order("id desc").Limit(deliveriesLimit).Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch deliveries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// GetWebhookDelivery returns a delivery with the log of its attempts (admin only)
+func GetWebhookDelivery(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var delivery models.WebhookDelivery
+	if err := config.DB.
+		Preload("DeliveryLog", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		First(&delivery, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, delivery)
+}
+
+// RedeliverWebhookDelivery queues a delivery again for an immediate attempt (admin only)
+func RedeliverWebhookDelivery(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var delivery models.WebhookDelivery
+	if err := config.DB.First(&delivery, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		return
+	}
+
+	var hook models.OutgoingWebhook
+	if err := config.DB.First(&hook, delivery.WebhookID).Error; err != nil {
+		c.JSON(http.StatusGone, gin.H{"error": "Webhook no longer exists"})
+		return
+	}
+	if !hook.Active {
+		c.JSON(http.StatusConflict, gin.H{"error": "Webhook is inactive"})
+		return
+	}
+
+	if Webhooks == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Webhook delivery is not running"})
+		return
+	}
+
+	if err := Webhooks.Redeliver(&delivery); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue redelivery"})
+		return
+	}
+
+	// The redelivery is queued by the dispatcher, the entry is recorded once it is
+	if err := recordAudit(config.DB, c, userID.(uint), auditEntry{
+		Action:     models.AuditWebhookRedelivered,
+		TargetType: models.AuditTargetWebhookDelivery,
+		TargetID:   delivery.ID,
+		Details:    gin.H{"webhook_id": delivery.WebhookID, "event_type": delivery.EventType},
+	}); err != nil {
+		log.Printf("Failed to record the redelivery of delivery %d: %v", delivery.ID, err)
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
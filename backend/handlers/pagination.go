@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"pictorial-backend/config"
+	"pictorial-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// Default and maximum number of messages per page
+	defaultPageLimit = 50
+	maxPageLimit     = 100
+)
+
+// MessagePage represents a page of messages, newest first.
+// NextCursor is passed as "before" to get older messages, PrevCursor as "after" to get newer ones.
+type MessagePage struct {
+	Messages   []models.MessageResponse `json:"messages"`
+	HasMore    bool                     `json:"has_more"`
+	NextCursor *uint                    `json:"next_cursor"`
+	PrevCursor *uint                    `json:"prev_cursor"`
+}
+
+// pageRequest holds the parsed pagination parameters
+type pageRequest struct {
+	before uint
+	after  uint
+	around uint
+	limit  int
+}
+
+// parsePageRequest reads the before, after, around and limit query parameters.
+// At most one of before, after and around can be given.
+func parsePageRequest(c *gin.Context) (pageRequest, bool) {
+	req := pageRequest{limit: defaultPageLimit}
+
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return req, false
+		}
+		if limit > maxPageLimit {
+			limit = maxPageLimit
+		}
+		req.limit = limit
+	}
+
+	cursors := 0
+	for _, cursor := range []struct {
+		name  string
+		value *uint
+	}{
+		{"before", &req.before},
+		{"after", &req.after},
+		{"around", &req.around},
+	} {
+		raw := c.Query(cursor.name)
+		if raw == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil || id == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + cursor.name})
+			return req, false
+		}
+		*cursor.value = uint(id)
+		cursors++
+	}
+
+	if cursors > 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only one of before, after and around can be used"})
+		return req, false
+	}
+
+	return req, true
+}
+
+// fetchMessages loads up to limit messages matched by scope on one side of a message ID
+func fetchMessages(scope func(*gorm.DB) *gorm.DB, viewer *models.User, condition string, id uint, order string, limit int) ([]models.Message, error) {
+	query := config.DB.Preload("User").Scopes(scope, notExpired, visibleTo(viewer))
+	if id > 0 {
+		query = query.Where(condition, id)
+	}
+
+	var messages []models.Message
+	err := query.Order(order).Limit(limit).Find(&messages).Error
+	return messages, err
+}
+
+// messagesExist checks whether scope matches any message on one side of a message ID
+func messagesExist(scope func(*gorm.DB) *gorm.DB, viewer *models.User, condition string, id uint) (bool, error) {
+	var ids []uint
+	err := config.DB.Model(&models.Message{}).
+		Scopes(scope, notExpired, visibleTo(viewer)).
+		Where(condition, id).
+		Limit(1).
+		Pluck("messages.id", &ids).Error
+	return len(ids) > 0, err
+}
+
+// reverseMessages reverses a slice of messages in place
+func reverseMessages(messages []models.Message) {
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+}
+
+// paginateMessages loads a page of the messages matched by scope using keyset pagination on the message ID.
+// Messages are returned newest first and decorated for the viewer, who does not see the messages of shadow-banned users.
+func paginateMessages(scope func(*gorm.DB) *gorm.DB, req pageRequest, viewer *models.User) (*MessagePage, error) {
+	var messages []models.Message
+	var hasOlder, hasNewer bool
+	var err error
+
+	switch {
+	case req.after > 0:
+		// Oldest messages after the cursor, reversed to keep the newest first order
+		if messages, err = fetchMessages(scope, viewer, "messages.id > ?", req.after, "messages.id asc", req.limit+1); err != nil {
+			return nil, err
+		}
+		if hasNewer = len(messages) > req.limit; hasNewer {
+			messages = messages[:req.limit]
+		}
+		reverseMessages(messages)
+		if hasOlder, err = messagesExist(scope, viewer, "messages.id <= ?", req.after); err != nil {
+			return nil, err
+		}
+
+	case req.around > 0:
+		// The cursor message and the older half of the page, then the newer half
+		newerLimit := req.limit / 2
+		olderLimit := req.limit - newerLimit
+
+		older, err := fetchMessages(scope, viewer, "messages.id <= ?", req.around, "messages.id desc", olderLimit+1)
+		if err != nil {
+			return nil, err
+		}
+		if hasOlder = len(older) > olderLimit; hasOlder {
+			older = older[:olderLimit]
+		}
+
+		newer, err := fetchMessages(scope, viewer, "messages.id > ?", req.around, "messages.id asc", newerLimit+1)
+		if err != nil {
+			return nil, err
+		}
+		if hasNewer = len(newer) > newerLimit; hasNewer {
+			newer = newer[:newerLimit]
+		}
+		reverseMessages(newer)
+
+		messages = append(newer, older...)
+
+	default:
+		// Newest messages, or the newest ones before the cursor
+		if messages, err = fetchMessages(scope, viewer, "messages.id < ?", req.before, "messages.id desc", req.limit+1); err != nil {
+			return nil, err
+		}
+		if hasOlder = len(messages) > req.limit; hasOlder {
+			messages = messages[:req.limit]
+		}
+		if req.before > 0 {
+			if hasNewer, err = messagesExist(scope, viewer, "messages.id >= ?", req.before); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	page := &MessagePage{Messages: []models.MessageResponse{}}
+	for _, msg := range messages {
+		page.Messages = append(page.Messages, msg.ToResponse())
+	}
+	if err := decorateMessages(page.Messages, viewer.ID); err != nil {
+		return nil, err
+	}
+
+	// has_more tells whether the requested direction continues, older messages unless paging forward
+	switch {
+	case req.after > 0:
+		page.HasMore = hasNewer
+	case req.around > 0:
+		page.HasMore = hasOlder || hasNewer
+	default:
+		page.HasMore = hasOlder
+	}
+
+	if len(messages) > 0 {
+		if hasOlder {
+			oldest := messages[len(messages)-1].ID
+			page.NextCursor = &oldest
+		}
+		if hasNewer {
+			newest := messages[0].ID
+			page.PrevCursor = &newest
+		}
+	} else {
+		// Keep the cursor usable when the page is empty
+		if hasOlder && req.after > 0 {
+			cursor := req.after + 1
+			page.NextCursor = &cursor
+		}
+		if hasNewer && req.before > 1 {
+			cursor := req.before - 1
+			page.PrevCursor = &cursor
+		}
+	}
+
+	return page, nil
+}
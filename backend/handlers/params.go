@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseID reads a numeric path parameter, writing a 400 response if it is invalid
+func parseID(c *gin.Context, param string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(param), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param})
+		return 0, false
+	}
+	return uint(id), true
+}
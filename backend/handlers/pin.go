@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"pictorial-backend/config"
+	"pictorial-backend/models"
+	ws "pictorial-backend/websocket"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Maximum number of pinned messages per channel
+const maxPinsPerChannel = 50
+
+// PinMessage pins a message in its channel (moderators and admins)
+func PinMessage(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var message models.Message
+	if err := config.DB.Preload("User").Scopes(notExpired).First(&message, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+
+	if message.PinnedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Message is already pinned"})
+		return
+	}
+
+	var pinCount int64
+	if err := config.DB.Model(&models.Message{}).
+		Where("channel_id = ? AND pinned_at IS NOT NULL", message.ChannelID).
+		Count(&pinCount).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count pinned messages"})
+		return
+	}
+	if pinCount >= maxPinsPerChannel {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("A channel can have at most %d pinned messages", maxPinsPerChannel)})
+		return
+	}
+
+	// Pinning does not count as an edit, so hooks and updated_at are skipped
+	now := time.Now()
+	pinnedBy := userID.(uint)
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&message).UpdateColumns(map[string]interface{}{
+			"pinned_at":    now,
+			"pinned_by_id": pinnedBy,
+		}).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, pinnedBy, auditEntry{
+			Action:     models.AuditMessagePinned,
+			TargetType: models.AuditTargetMessage,
+			TargetID:   message.ID,
+			Details:    gin.H{"channel_id": message.ChannelID},
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to pin message"})
+		return
+	}
+	message.PinnedAt = &now
+	message.PinnedByID = &pinnedBy
+
+	response := message.ToResponse()
+
+	if Hub != nil {
+		broadcast := response
+		decorateMessage(&broadcast, 0)
+		Hub.BroadcastToChannel(message.ChannelID, ws.Event{
+			Type:      ws.EventMessagePinned,
+			ChannelID: message.ChannelID,
+			Data:      broadcast,
+		})
+	}
+
+	decorateMessage(&response, pinnedBy)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// UnpinMessage removes a message from its channel's pins (moderators and admins)
+func UnpinMessage(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var message models.Message
+	if err := config.DB.Preload("User").Scopes(notExpired).First(&message, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+
+	if message.PinnedAt == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Message is not pinned"})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&message).UpdateColumns(map[string]interface{}{
+			"pinned_at":    nil,
+			"pinned_by_id": nil,
+		}).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, c.GetUint("userID"), auditEntry{
+			Action:     models.AuditMessageUnpinned,
+			TargetType: models.AuditTargetMessage,
+			TargetID:   message.ID,
+			Details:    gin.H{"channel_id": message.ChannelID, "pinned_by_id": message.PinnedByID},
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unpin message"})
+		return
+	}
+	message.PinnedAt = nil
+	message.PinnedByID = nil
+
+	response := message.ToResponse()
+	decorateMessage(&response, c.GetUint("userID"))
+
+	if Hub != nil {
+		Hub.BroadcastToChannel(message.ChannelID, ws.Event{
+			Type:      ws.EventMessageUnpinned,
+			ChannelID: message.ChannelID,
+			Data:      gin.H{"message_id": message.ID},
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetChannelPins returns the pinned messages of a channel, most recently pinned first
+func GetChannelPins(c *gin.Context) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	channel, ok := loadAccessibleChannel(c, user, id)
+	if !ok {
+		return
+	}
+
+	var messages []models.Message
+	if err := config.DB.
+		Preload("User").
+		Scopes(notExpired).
+		Where("channel_id = ? AND pinned_at IS NOT NULL", channel.ID).
+		Order("pinned_at desc").
+		Find(&messages).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pinned messages"})
+		return
+	}
+
+	responses := []models.MessageResponse{}
+	for _, msg := range messages {
+		responses = append(responses, msg.ToResponse())
+	}
+	if err := decorateMessages(responses, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pinned messages"})
+		return
+	}
+
+	c.JSON(http.StatusOK, responses)
+}
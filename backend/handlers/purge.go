@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"pictorial-backend/config"
+	"pictorial-backend/models"
+	"pictorial-backend/webhooks"
+	ws "pictorial-backend/websocket"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// Number of messages deleted per statement
+	purgeBatchSize = 500
+
+	// Maximum number of messages deleted by a single purge
+	maxPurgeMessages = 10000
+)
+
+// PurgeMessagesRequest selects the messages to purge. At least one filter is required, filters are combined.
+type PurgeMessagesRequest struct {
+	UserID    *uint      `json:"user_id"`
+	ChannelID *uint      `json:"channel_id"`
+	From      *time.Time `json:"from"`
+	To        *time.Time `json:"to"`
+	Contains  string     `json:"contains" binding:"max=200"` // Case-insensitive text match
+	DryRun    bool       `json:"dry_run"`                    // Only count the matching messages
+}
+
+// PurgeResult reports what a purge deleted
+type PurgeResult struct {
+	Deleted   int          `json:"deleted"`
+	Channels  map[uint]int `json:"channels"`  // Deleted messages per channel
+	Truncated bool         `json:"truncated"` // More messages match, run the purge again
+	DryRun    bool         `json:"dry_run"`
+}
+
+// bulkDeletedMessages is the payload of the bulk delete event
+type bulkDeletedMessages struct {
+	ChannelID  uint   `json:"channel_id"`
+	MessageIDs []uint `json:"message_ids"`
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// purgeFilter returns the scope selecting the messages of a purge request
+func purgeFilter(req *PurgeMessagesRequest) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if req.UserID != nil {
+			db = db.Where("messages.user_id = ?", *req.UserID)
+		}
+		if req.ChannelID != nil {
+			db = db.Where("messages.channel_id = ?", *req.ChannelID)
+		}
+		if req.From != nil {
+			db = db.Where("messages.created_at >= ?", *req.From)
+		}
+		if req.To != nil {
+			db = db.Where("messages.created_at < ?", *req.To)
+		}
+		if req.Contains != "" {
+			db = db.Where("messages.content ILIKE ?", "%"+escapeLike(req.Contains)+"%")
+		}
+		return db
+	}
+}
+
+// PurgeMessages deletes every message matching the filters in one transaction (admin only).
+// Each affected channel receives a single bulk delete event.
+func PurgeMessages(c *gin.Context) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	var req PurgeMessagesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.Contains = strings.TrimSpace(req.Contains)
+	if req.UserID == nil && req.ChannelID == nil && req.From == nil && req.To == nil && req.Contains == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one filter is required"})
+		return
+	}
+	if req.From != nil && req.To != nil && !req.From.Before(*req.To) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+
+	result := PurgeResult{Channels: map[uint]int{}, DryRun: req.DryRun}
+
+	if req.DryRun {
+		var counts []struct {
+			ChannelID uint
+			Count     int
+		}
+		if err := config.DB.Model(&models.Message{}).
+			Scopes(purgeFilter(&req)).
+			Select("messages.channel_id, COUNT(*) AS count").
+			Group("messages.channel_id").
+			Scan(&counts).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count messages"})
+			return
+		}
+		for _, count := range counts {
+			result.Channels[count.ChannelID] = count.Count
+			result.Deleted += count.Count
+		}
+		c.JSON(http.StatusOK, result)
+		return
+	}
+
+	var deleted []models.Message
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		for len(deleted) < maxPurgeMessages {
+			limit := purgeBatchSize
+			if remaining := maxPurgeMessages - len(deleted); remaining < limit {
+				limit = remaining
+			}
+
+			var batch []models.Message
+			if err := tx.Model(&models.Message{}).
+				Scopes(purgeFilter(&req)).
+				Select("id", "channel_id", "parent_id").
+				Order("id").
+				Limit(limit).
+				Find(&batch).Error; err != nil {
+				return err
+			}
+			if len(batch) == 0 {
+				break
+			}
+
+			ids := make([]uint, len(batch))
+			for i, msg := range batch {
+				ids[i] = msg.ID
+			}
+			if err := softDeleteMessages(tx, user.ID, ids...); err != nil {
+				return err
+			}
+
+			deleted = append(deleted, batch...)
+		}
+
+		for _, msg := range deleted {
+			result.Channels[msg.ChannelID]++
+		}
+		result.Deleted = len(deleted)
+
+		if len(deleted) == maxPurgeMessages {
+			var more []uint
+			if err := tx.Model(&models.Message{}).Scopes(purgeFilter(&req)).Limit(1).Pluck("messages.id", &more).Error; err != nil {
+				return err
+			}
+			result.Truncated = len(more) > 0
+		}
+
+		return recordAudit(tx, c, user.ID, auditEntry{
+			Action: models.AuditMessagesPurged,
+			Details: gin.H{
+				"filters":   req,
+				"deleted":   result.Deleted,
+				"channels":  result.Channels,
+				"truncated": result.Truncated,
+			},
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purge messages"})
+		return
+	}
+
+	broadcastBulkDelete(deleted, user.ID)
+
+	c.JSON(http.StatusOK, result)
+}
+
+// broadcastBulkDelete sends one bulk delete event per channel and updates the threads that lost replies
+func broadcastBulkDelete(deleted []models.Message, deletedByID uint) {
+	byChannel := map[uint][]uint{}
+	deletedIDs := make(map[uint]bool, len(deleted))
+	for _, msg := range deleted {
+		byChannel[msg.ChannelID] = append(byChannel[msg.ChannelID], msg.ID)
+		deletedIDs[msg.ID] = true
+	}
+
+	for channelID, ids := range byChannel {
+		payload := bulkDeletedMessages{ChannelID: channelID, MessageIDs: ids}
+		if Hub != nil {
+			Hub.BroadcastToChannel(channelID, ws.Event{
+				Type:      ws.EventMessagesBulkDeleted,
+				ChannelID: channelID,
+				Data:      payload,
+			})
+		}
+		emitWebhookEvent(webhooks.EventMessagesPurged, gin.H{
+			"channel_id":    channelID,
+			"message_ids":   ids,
+			"deleted_by_id": deletedByID,
+		})
+	}
+
+	threads := map[uint]uint{}
+	for _, msg := range deleted {
+		if msg.ParentID != nil && !deletedIDs[*msg.ParentID] {
+			threads[*msg.ParentID] = msg.ChannelID
+		}
+	}
+	for parentID, channelID := range threads {
+		broadcastThreadUpdate(parentID, channelID)
+	}
+}
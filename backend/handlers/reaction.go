@@ -0,0 +1,471 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"pictorial-backend/config"
+	"pictorial-backend/models"
+	ws "pictorial-backend/websocket"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// Maximum number of different reactions on a single message
+	maxReactionKinds = 20
+
+	// Maximum length of an emoji reaction (flags, skin tones and ZWJ sequences take several runes)
+	maxEmojiRunes = 16
+
+	// Stamps are small images
+	maxStampBytes      = 256 << 10
+	maxStampDimensions = 128
+
+	// Reactions with a stamp are written "stamp:<id>" in URLs
+	stampReactionPrefix = "stamp:"
+)
+
+// Stamp names are short lowercase identifiers
+var stampNamePattern = regexp.MustCompile(`^[a-z0-9_]{2,32}$`)
+
+// CreateStampRequest represents the stamp upload request
+type CreateStampRequest struct {
+	Name      string `json:"name" binding:"required"`
+	ImageData string `json:"image_data" binding:"required"` // Base64 encoded PNG image
+}
+
+// isEmoji checks that a reaction looks like a single emoji rather than arbitrary text
+func isEmoji(value string) bool {
+	if value == "" || !utf8.ValidString(value) || utf8.RuneCountInString(value) > maxEmojiRunes {
+		return false
+	}
+
+	hasSymbol := false
+	for _, r := range value {
+		if unicode.IsSpace(r) || unicode.IsControl(r) || unicode.IsLetter(r) {
+			return false
+		}
+		// Emoji are "other symbols", keycaps like 1️⃣ use an enclosing mark
+		if unicode.Is(unicode.So, r) || unicode.Is(unicode.Me, r) {
+			hasSymbol = true
+		}
+	}
+	return hasSymbol
+}
+
+// parseReaction reads the :reaction path parameter, an emoji or "stamp:<id>", writing a 400 response if invalid
+func parseReaction(c *gin.Context) (models.Reaction, bool) {
+	value := c.Param("reaction")
+
+	if strings.HasPrefix(value, stampReactionPrefix) {
+		stampID, err := strconv.ParseUint(strings.TrimPrefix(value, stampReactionPrefix), 10, 32)
+		if err != nil || stampID == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stamp"})
+			return models.Reaction{}, false
+		}
+		return models.Reaction{StampID: uint(stampID)}, true
+	}
+
+	if !isEmoji(value) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reaction must be an emoji or stamp:<id>"})
+		return models.Reaction{}, false
+	}
+	return models.Reaction{Emoji: value}, true
+}
+
+// loadReactableMessage fetches a message in a channel the user can access, writing a 404 response otherwise
+func loadReactableMessage(c *gin.Context, user *models.User) (*models.Message, bool) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return nil, false
+	}
+
+	var message models.Message
+	if err := config.DB.Preload("Channel").Scopes(notExpired, visibleTo(user)).First(&message, id).Error; err != nil || !canAccessChannel(user, &message.Channel) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return nil, false
+	}
+	return &message, true
+}
+
+// countReactions counts the reactions of one kind on a message, as seen by a viewer
+func countReactions(messageID uint, reaction *models.Reaction, viewerID uint) (int64, error) {
+	var count int64
+	err := config.DB.Model(&models.Reaction{}).
+		Scopes(reactionsVisibleTo(viewerID)).
+		Where("message_id = ? AND emoji = ? AND stamp_id = ?", messageID, reaction.Emoji, reaction.StampID).
+		Count(&count).Error
+	return count, err
+}
+
+// broadcastReaction notifies the channel that a reaction was added or removed.
+// The reactions of shadow-banned users only go back to their own connections.
+func broadcastReaction(eventType string, message *models.Message, reaction *models.Reaction, user *models.User, count int64) {
+	if Hub == nil {
+		return
+	}
+
+	event := ws.Event{
+		Type:      eventType,
+		ChannelID: message.ChannelID,
+		Data: gin.H{
+			"message_id": message.ID,
+			"user_id":    user.ID,
+			"emoji":      reaction.Emoji,
+			"stamp_id":   reaction.StampID,
+			"count":      count,
+		},
+	}
+	if user.ShadowBanned {
+		Hub.SendToUser(user.ID, event)
+		return
+	}
+
+	// Everyone else receives the count of the reactions they can see
+	publicCount, err := countReactions(message.ID, reaction, 0)
+	if err != nil {
+		log.Printf("Failed to count reactions on message %d: %v", message.ID, err)
+		return
+	}
+	event.Data.(gin.H)["count"] = publicCount
+	Hub.BroadcastToChannel(message.ChannelID, event)
+}
+
+// AddReaction reacts to a message with an emoji or a stamp
+func AddReaction(c *gin.Context) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	message, ok := loadReactableMessage(c, user)
+	if !ok {
+		return
+	}
+
+	reaction, ok := parseReaction(c)
+	if !ok {
+		return
+	}
+
+	if reaction.StampID != 0 {
+		var stamp models.Stamp
+		if err := config.DB.First(&stamp, reaction.StampID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Stamp not found"})
+			return
+		}
+	}
+
+	// Only check the limit when the reaction would add a new kind
+	count, err := countReactions(message.ID, &reaction, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count reactions"})
+		return
+	}
+	if count == 0 {
+		var kinds int64
+		if err := config.DB.Model(&models.Reaction{}).
+			Select("COUNT(DISTINCT (emoji, stamp_id))").
+			Where("message_id = ?", message.ID).
+			Scan(&kinds).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count reactions"})
+			return
+		}
+		if kinds >= maxReactionKinds {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("A message can have at most %d different reactions", maxReactionKinds)})
+			return
+		}
+	}
+
+	reaction.MessageID = message.ID
+	reaction.UserID = user.ID
+
+	// Reacting twice the same way is not an error
+	result := config.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&reaction)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add reaction"})
+		return
+	}
+
+	if count, err = countReactions(message.ID, &reaction, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count reactions"})
+		return
+	}
+
+	status := http.StatusOK
+	if result.RowsAffected > 0 {
+		status = http.StatusCreated
+		broadcastReaction(ws.EventReactionAdded, message, &reaction, user, count)
+	}
+
+	c.JSON(status, models.ReactionSummary{
+		Emoji:   reaction.Emoji,
+		StampID: reaction.StampID,
+		Count:   count,
+		Me:      true,
+	})
+}
+
+// RemoveReaction removes the current user's reaction from a message
+func RemoveReaction(c *gin.Context) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	message, ok := loadReactableMessage(c, user)
+	if !ok {
+		return
+	}
+
+	reaction, ok := parseReaction(c)
+	if !ok {
+		return
+	}
+
+	result := config.DB.
+		Where("message_id = ? AND user_id = ? AND emoji = ? AND stamp_id = ?", message.ID, user.ID, reaction.Emoji, reaction.StampID).
+		Delete(&models.Reaction{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove reaction"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reaction not found"})
+		return
+	}
+
+	count, err := countReactions(message.ID, &reaction, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count reactions"})
+		return
+	}
+
+	broadcastReaction(ws.EventReactionRemoved, message, &reaction, user, count)
+
+	c.JSON(http.StatusOK, models.ReactionSummary{
+		Emoji:   reaction.Emoji,
+		StampID: reaction.StampID,
+		Count:   count,
+		Me:      false,
+	})
+}
+
+// GetReactionUsers lists the users who reacted to a message in a given way
+func GetReactionUsers(c *gin.Context) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	message, ok := loadReactableMessage(c, user)
+	if !ok {
+		return
+	}
+
+	reaction, ok := parseReaction(c)
+	if !ok {
+		return
+	}
+
+	var users []models.User
+	if err := config.DB.
+		Joins("JOIN reactions ON reactions.user_id = users.id").
+		Scopes(reactionsVisibleTo(user.ID)).
+		Where("reactions.message_id = ? AND reactions.emoji = ? AND reactions.stamp_id = ?", message.ID, reaction.Emoji, reaction.StampID).
+		Order("reactions.id").
+		Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reactions"})
+		return
+	}
+
+	responses := []models.UserResponse{}
+	for _, u := range users {
+		responses = append(responses, u.ToResponse())
+	}
+
+	c.JSON(http.StatusOK, responses)
+}
+
+// attachReactions fills the reaction summaries of message responses with a single grouped query
+func attachReactions(responses []models.MessageResponse, viewerID uint) error {
+	if len(responses) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(responses))
+	for i := range responses {
+		ids[i] = responses[i].ID
+	}
+
+	var rows []struct {
+		MessageID uint
+		models.ReactionSummary
+	}
+	if err := config.DB.Model(&models.Reaction{}).
+		Select("message_id, emoji, stamp_id, COUNT(*) AS count, BOOL_OR(user_id = ?) AS me", viewerID).
+		Scopes(reactionsVisibleTo(viewerID)).
+		Where("message_id IN ?", ids).
+		Group("message_id, emoji, stamp_id").
+		Order("MIN(id)").
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	byMessage := make(map[uint][]models.ReactionSummary, len(rows))
+	for _, row := range rows {
+		byMessage[row.MessageID] = append(byMessage[row.MessageID], row.ReactionSummary)
+	}
+
+	for i := range responses {
+		if summaries, ok := byMessage[responses[i].ID]; ok {
+			responses[i].Reactions = summaries
+		} else {
+			responses[i].Reactions = []models.ReactionSummary{}
+		}
+	}
+	return nil
+}
+
+// GetStamps lists the stamps available for reactions
+func GetStamps(c *gin.Context) {
+	var stamps []models.Stamp
+	if err := config.DB.Order("name").Find(&stamps).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stamps"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stamps)
+}
+
+// GetStampImage returns the image of a stamp
+func GetStampImage(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	// Deleted stamps stay visible on the messages that used them
+	var stamp models.Stamp
+	if err := config.DB.Unscoped().First(&stamp, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stamp not found"})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=86400")
+	servePNG(c, stamp.Image)
+}
+
+// CreateStamp uploads a new stamp (admin only)
+func CreateStamp(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req CreateStampRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !stampNamePattern.MatchString(req.Name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Stamp name must be 2 to 32 lowercase letters, digits or underscores"})
+		return
+	}
+
+	imageBytes, err := decodeImageData(req.ImageData)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image data"})
+		return
+	}
+	if len(imageBytes) > maxStampBytes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Stamp images are limited to %d KB", maxStampBytes>>10)})
+		return
+	}
+
+	imageConfig, err := png.DecodeConfig(bytes.NewReader(imageBytes))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Stamp image must be a PNG"})
+		return
+	}
+	if imageConfig.Width > maxStampDimensions || imageConfig.Height > maxStampDimensions {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Stamp images are limited to %dx%d pixels", maxStampDimensions, maxStampDimensions)})
+		return
+	}
+
+	stamp := models.Stamp{
+		Name:      req.Name,
+		Image:     imageBytes,
+		CreatorID: userID.(uint),
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&stamp).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, stamp.CreatorID, auditEntry{
+			Action:     models.AuditStampCreated,
+			TargetType: models.AuditTargetStamp,
+			TargetID:   stamp.ID,
+			After:      stamp,
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Stamp name already taken"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, stamp)
+}
+
+// DeleteStamp removes a stamp from the ones available for reactions (admin only)
+func DeleteStamp(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var stamp models.Stamp
+	if err := config.DB.First(&stamp, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stamp not found"})
+		return
+	}
+
+	// Existing reactions keep the stamp, its image stays available
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&stamp).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, userID.(uint), auditEntry{
+			Action:     models.AuditStampDeleted,
+			TargetType: models.AuditTargetStamp,
+			TargetID:   stamp.ID,
+			Before:     stamp,
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete stamp"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Stamp deleted successfully"})
+}
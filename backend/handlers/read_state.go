@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"pictorial-backend/config"
+	"pictorial-backend/models"
+	ws "pictorial-backend/websocket"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Maximum number of readers listed for a message
+const maxMessageReaders = 100
+
+// MarkReadRequest represents the read marker update request
+type MarkReadRequest struct {
+	MessageID uint `json:"message_id"` // Last read message, defaults to the channel's latest message
+}
+
+// ReadState represents a user's read marker and unread counters in a channel
+type ReadState struct {
+	ChannelID         uint  `json:"channel_id"`
+	LastReadMessageID uint  `json:"last_read_message_id"`
+	UnreadCount       int64 `json:"unread_count"`
+	MentionCount      int64 `json:"mention_count"`
+}
+
+// ChannelListItem represents a channel in the channel list, with the user's read state
+type ChannelListItem struct {
+	models.Channel
+	LastReadMessageID uint  `json:"last_read_message_id"`
+	UnreadCount       int64 `json:"unread_count"`
+	MentionCount      int64 `json:"mention_count"`
+}
+
+// readStates computes the read marker and unread counters of a user in the given channels.
+// The user's own messages, and those hidden from them by a shadow ban, never count as unread.
+func readStates(user *models.User, channelIDs []uint) (map[uint]ReadState, error) {
+	states := make(map[uint]ReadState, len(channelIDs))
+	if len(channelIDs) == 0 {
+		return states, nil
+	}
+
+	var markers []models.ChannelReadState
+	if err := config.DB.Where("user_id = ? AND channel_id IN ?", user.ID, channelIDs).Find(&markers).Error; err != nil {
+		return nil, err
+	}
+	for _, marker := range markers {
+		states[marker.ChannelID] = ReadState{ChannelID: marker.ChannelID, LastReadMessageID: marker.LastReadMessageID}
+	}
+
+	var counts []ReadState
+	if err := config.DB.Model(&models.Message{}).
+		Select("messages.channel_id, COUNT(*) AS unread_count, COUNT(mentions.id) AS mention_count").
+		Joins("LEFT JOIN channel_read_states ON channel_read_states.channel_id = messages.channel_id AND channel_read_states.user_id = ?", user.ID).
+		Joins("LEFT JOIN mentions ON mentions.message_id = messages.id AND mentions.user_id = ?", user.ID).
+		Scopes(notExpired, visibleTo(user)).
+		Where("messages.channel_id IN ? AND messages.user_id <> ?", channelIDs, user.ID).
+		Where("messages.id > COALESCE(channel_read_states.last_read_message_id, 0)").
+		Group("messages.channel_id").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	for _, count := range counts {
+		state := states[count.ChannelID]
+		state.ChannelID = count.ChannelID
+		state.UnreadCount = count.UnreadCount
+		state.MentionCount = count.MentionCount
+		states[count.ChannelID] = state
+	}
+
+	return states, nil
+}
+
+// MarkChannelRead moves the current user's read marker in a channel forward
+func MarkChannelRead(c *gin.Context) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	channel, ok := loadAccessibleChannel(c, user, id)
+	if !ok {
+		return
+	}
+
+	// The body is optional, the whole channel is marked as read when it is empty
+	var req MarkReadRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.MessageID == 0 {
+		var latest []uint
+		if err := config.DB.Model(&models.Message{}).
+			Scopes(notExpired).
+			Where("channel_id = ?", channel.ID).
+			Order("id desc").
+			Limit(1).
+			Pluck("id", &latest).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find the latest message"})
+			return
+		}
+		if len(latest) > 0 {
+			req.MessageID = latest[0]
+		}
+	} else {
+		var message models.Message
+		if err := config.DB.Unscoped().Where("channel_id = ?", channel.ID).First(&message, req.MessageID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Message not found in this channel"})
+			return
+		}
+	}
+
+	// The marker never moves backwards, so devices racing each other agree
+	marker := models.ChannelReadState{
+		UserID:            user.ID,
+		ChannelID:         channel.ID,
+		LastReadMessageID: req.MessageID,
+	}
+	if err := config.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "channel_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"last_read_message_id": gorm.Expr("GREATEST(channel_read_states.last_read_message_id, excluded.last_read_message_id)"),
+			"updated_at":           gorm.Expr("excluded.updated_at"),
+		}),
+	}).Create(&marker).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update read marker"})
+		return
+	}
+
+	states, err := readStates(user, []uint{channel.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count unread messages"})
+		return
+	}
+	state := states[channel.ID]
+	state.ChannelID = channel.ID
+
+	// Keep the user's other devices in sync
+	if Hub != nil {
+		Hub.SendToUser(user.ID, ws.Event{
+			Type:      ws.EventReadMarkerUpdated,
+			ChannelID: channel.ID,
+			Data:      state,
+		})
+	}
+
+	c.JSON(http.StatusOK, state)
+}
+
+// GetMessageReaders lists the users whose read marker is at or past a message
+func GetMessageReaders(c *gin.Context) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var message models.Message
+	if err := config.DB.Preload("Channel").Scopes(notExpired, visibleTo(user)).First(&message, id).Error; err != nil || !canAccessChannel(user, &message.Channel) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+
+	var readers []models.User
+	if err := config.DB.
+		Joins("JOIN channel_read_states ON channel_read_states.user_id = users.id").
+		Where("channel_read_states.channel_id = ? AND channel_read_states.last_read_message_id >= ?", message.ChannelID, message.ID).
+		Where("users.id <> ?", message.UserID).
+		Order("channel_read_states.updated_at").
+		Limit(maxMessageReaders).
+		Find(&readers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch readers"})
+		return
+	}
+
+	responses := []models.UserResponse{}
+	for _, reader := range readers {
+		responses = append(responses, reader.ToResponse())
+	}
+
+	c.JSON(http.StatusOK, responses)
+}
@@ -0,0 +1,412 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"pictorial-backend/config"
+	"pictorial-backend/models"
+	ws "pictorial-backend/websocket"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Maximum number of reports listed at once
+const maxReportsListed = 100
+
+// errReportResolved is returned when a report was resolved by another moderator in the meantime
+var errReportResolved = errors.New("report already resolved")
+
+// ReportMessageRequest represents the message report request
+type ReportMessageRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+// ResolveReportRequest represents the moderator's decision on a report
+type ResolveReportRequest struct {
+	Action          string `json:"action" binding:"required,oneof=dismiss delete_message mute_author ban_author"`
+	Note            string `json:"note" binding:"max=500"`           // Shown to the reporters
+	DurationSeconds int    `json:"duration_seconds" binding:"min=0"` // Length of a mute or ban, permanent when 0
+}
+
+// ReportMessage flags a message for the moderators, keeping a snapshot of it
+func ReportMessage(c *gin.Context) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req ReportMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required"})
+		return
+	}
+
+	var message models.Message
+	if err := config.DB.Preload("Channel").Scopes(notExpired, visibleTo(user)).First(&message, id).Error; err != nil || !canAccessChannel(user, &message.Channel) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+
+	if message.UserID == user.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot report your own messages"})
+		return
+	}
+
+	report := models.MessageReport{
+		MessageID:        message.ID,
+		ReporterID:       &user.ID,
+		Reason:           reason,
+		ChannelID:        message.ChannelID,
+		AuthorID:         message.UserID,
+		Content:          message.Content,
+		Image:            message.Image,
+		ImageKey:         message.ImageKey,
+		NbOfLines:        message.NbOfLines,
+		MessageCreatedAt: message.CreatedAt,
+		Status:           models.ReportStatusOpen,
+	}
+
+	result := config.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&report)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to report message"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "You already reported this message"})
+		return
+	}
+
+	notifyModerators(&report)
+
+	c.JSON(http.StatusCreated, report.ToOutcome())
+}
+
+// notifyModerators pushes a new report to the connected moderators who can see its channel
+func notifyModerators(report *models.MessageReport) {
+	if Hub == nil {
+		return
+	}
+
+	var channel models.Channel
+	if err := config.DB.First(&channel, report.ChannelID).Error; err != nil {
+		return
+	}
+
+	var moderators []models.User
+	if err := config.DB.Where("role IN ?", []string{"moderator", "admin"}).Find(&moderators).Error; err != nil {
+		return
+	}
+
+	config.DB.Preload("Reporter").Preload("Author").First(report, report.ID)
+	response := report.ToResponse()
+
+	for _, moderator := range moderators {
+		if canAccessChannel(&moderator, &channel) {
+			Hub.SendToUser(moderator.ID, ws.Event{
+				Type:      ws.EventReportCreated,
+				ChannelID: report.ChannelID,
+				Data:      response,
+			})
+		}
+	}
+}
+
+// GetMyReports lists the reports filed by the current user and their outcome, newest first
+func GetMyReports(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var reports []models.MessageReport
+	if err := config.DB.Where("reporter_id = ?", userID).Order("id desc").Limit(maxReportsListed).Find(&reports).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reports"})
+		return
+	}
+
+	outcomes := []models.ReportOutcome{}
+	for _, report := range reports {
+		outcomes = append(outcomes, report.ToOutcome())
+	}
+
+	c.JSON(http.StatusOK, outcomes)
+}
+
+// GetReports lists the moderation queue (moderator only). Open reports are listed oldest first,
+// resolved ones most recently resolved first.
+func GetReports(c *gin.Context) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	status := c.DefaultQuery("status", models.ReportStatusOpen)
+	if status != models.ReportStatusOpen && status != models.ReportStatusResolved {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status, expected open or resolved"})
+		return
+	}
+
+	limit := maxReportsListed
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		if parsed < limit {
+			limit = parsed
+		}
+	}
+
+	query := config.DB.Preload("Reporter").Preload("Author").Where("status = ?", status)
+	if !user.IsAdmin() {
+		channels := config.DB.Model(&models.Channel{}).Select("channels.id").Scopes(accessibleChannels(user))
+		query = query.Where("message_reports.channel_id IN (?)", channels)
+	}
+	if raw := c.Query("channel_id"); raw != "" {
+		channelID, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channel_id"})
+			return
+		}
+		query = query.Where("message_reports.channel_id = ?", channelID)
+	}
+
+	if status == models.ReportStatusOpen {
+		query = query.Order("id")
+	} else {
+		query = query.Order("resolved_at desc")
+	}
+
+	var reports []models.MessageReport
+	if err := query.Limit(limit).Find(&reports).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reports"})
+		return
+	}
+
+	responses := []models.MessageReportResponse{}
+	for _, report := range reports {
+		responses = append(responses, report.ToResponse())
+	}
+
+	c.JSON(http.StatusOK, responses)
+}
+
+// loadReport fetches a report of a channel the moderator can see, writing a 404 response otherwise
+func loadReport(c *gin.Context, user *models.User) (*models.MessageReport, bool) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return nil, false
+	}
+
+	var report models.MessageReport
+	if err := config.DB.Preload("Reporter").Preload("Author").First(&report, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return nil, false
+	}
+
+	// Reports of deleted channels stay visible to admins only
+	var channel models.Channel
+	err := config.DB.First(&channel, report.ChannelID).Error
+	if (err != nil && !user.IsAdmin()) || (err == nil && !canAccessChannel(user, &channel)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return nil, false
+	}
+
+	return &report, true
+}
+
+// GetReport returns a report of the moderation queue (moderator only)
+func GetReport(c *gin.Context) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	report, ok := loadReport(c, user)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, report.ToResponse())
+}
+
+// GetReportImage returns the drawing of a reported message as it was when reported (moderator only)
+func GetReportImage(c *gin.Context) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	report, ok := loadReport(c, user)
+	if !ok {
+		return
+	}
+
+	if report.ImageKey == nil && len(report.Image) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reported message has no image"})
+		return
+	}
+
+	serveDrawing(c, report.ImageKey, report.Image)
+}
+
+// ResolveReport applies a moderator's decision to a report and to every other open report of the same message.
+// The reporters are told the outcome.
+func ResolveReport(c *gin.Context) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	report, ok := loadReport(c, user)
+	if !ok {
+		return
+	}
+
+	var req ResolveReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if report.Status != models.ReportStatusOpen {
+		c.JSON(http.StatusConflict, gin.H{"error": "Report was already resolved"})
+		return
+	}
+
+	var message models.Message
+	var sanction models.Sanction
+	switch req.Action {
+	case models.ReportActionDeleteMessage:
+		// The message may be gone already, the report is resolved all the same
+		config.DB.Scopes(notExpired).Find(&message, report.MessageID)
+
+	case models.ReportActionMuteAuthor, models.ReportActionBanAuthor:
+		if report.Author.ID == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Author not found"})
+			return
+		}
+		if !canSanction(user, &report.Author) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You cannot sanction this user"})
+			return
+		}
+		sanctionType := models.SanctionMute
+		if req.Action == models.ReportActionBanAuthor {
+			sanctionType = models.SanctionBan
+		}
+		sanction = newSanction(sanctionType, &report.Author, user, report.Reason, req.DurationSeconds)
+		sanction.ReportID = &report.ID
+	}
+
+	now := time.Now()
+	var resolved []models.MessageReport
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("message_id = ? AND status = ?", report.MessageID, models.ReportStatusOpen).Find(&resolved).Error; err != nil {
+			return err
+		}
+
+		ids := make([]uint, len(resolved))
+		for i := range resolved {
+			ids[i] = resolved[i].ID
+			resolved[i].Status = models.ReportStatusResolved
+			resolved[i].Action = req.Action
+			resolved[i].Note = req.Note
+			resolved[i].ResolvedByID = &user.ID
+			resolved[i].ResolvedAt = &now
+		}
+
+		// Claiming the reports fails if another moderator resolved them first
+		result := tx.Model(&models.MessageReport{}).
+			Where("id IN ? AND status = ?", ids, models.ReportStatusOpen).
+			Updates(map[string]interface{}{
+				"status":         models.ReportStatusResolved,
+				"action":         req.Action,
+				"note":           req.Note,
+				"resolved_by_id": user.ID,
+				"resolved_at":    now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if len(ids) == 0 || result.RowsAffected != int64(len(ids)) {
+			return errReportResolved
+		}
+
+		details := gin.H{"message_id": report.MessageID, "report_ids": ids}
+		if message.ID != 0 {
+			if err := softDeleteMessages(tx, user.ID, message.ID); err != nil {
+				return err
+			}
+			details["message"] = messageAuditSnapshot(&message)
+		}
+		if sanction.Type != "" {
+			if err := tx.Create(&sanction).Error; err != nil {
+				return err
+			}
+			details["sanction"] = sanction
+		}
+
+		return recordAudit(tx, c, user.ID, auditEntry{
+			Action:     models.AuditReportResolved,
+			TargetType: models.AuditTargetReport,
+			TargetID:   report.ID,
+			Before:     gin.H{"status": models.ReportStatusOpen},
+			After:      gin.H{"status": models.ReportStatusResolved, "action": req.Action, "note": req.Note},
+			Details:    details,
+		})
+	})
+	if errors.Is(err, errReportResolved) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Report was already resolved"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve report"})
+		return
+	}
+
+	if message.ID != 0 {
+		messageDeleted(&message, user.ID)
+	}
+	if sanction.ID != 0 {
+		sanctionIssued(&sanction)
+	}
+
+	if Hub != nil {
+		for _, r := range resolved {
+			if r.ReporterID == nil {
+				continue
+			}
+			Hub.SendToUser(*r.ReporterID, ws.Event{
+				Type: ws.EventReportResolved,
+				Data: r.ToOutcome(),
+			})
+		}
+	}
+
+	report.Status = models.ReportStatusResolved
+	report.Action = req.Action
+	report.Note = req.Note
+	report.ResolvedByID = &user.ID
+	report.ResolvedAt = &now
+
+	c.JSON(http.StatusOK, report.ToResponse())
+}
@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"pictorial-backend/config"
+	"pictorial-backend/models"
+	ws "pictorial-backend/websocket"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errSanctionLifted is returned when a sanction was lifted by another moderator in the meantime
+var errSanctionLifted = errors.New("sanction already lifted")
+
+// CreateSanctionRequest represents the mute or ban request
+type CreateSanctionRequest struct {
+	UserID          uint   `json:"user_id" binding:"required"`
+	Type            string `json:"type" binding:"required,oneof=mute ban"`
+	ChannelID       *uint  `json:"channel_id"` // Mutes only, global when omitted
+	Reason          string `json:"reason" binding:"max=500"`
+	DurationSeconds int    `json:"duration_seconds" binding:"min=0"` // Permanent when 0
+}
+
+// activeSanction returns the longest lasting active sanction of the given types against a user, nil if there is none.
+// Sanctions scoped to another channel are ignored, a channelID of 0 only matches global sanctions.
+func activeSanction(userID uint, channelID uint, types ...string) (*models.Sanction, error) {
+	var sanctions []models.Sanction
+	if err := config.DB.
+		Scopes(models.ActiveSanctions).
+		Where("user_id = ? AND type IN ?", userID, types).
+		Where("(channel_id IS NULL OR channel_id = ?)", channelID).
+		Order("expires_at DESC NULLS FIRST").
+		Limit(1).
+		Find(&sanctions).Error; err != nil {
+		return nil, err
+	}
+	if len(sanctions) == 0 {
+		return nil, nil
+	}
+	return &sanctions[0], nil
+}
+
+// sanctionError describes an active sanction to the sanctioned user
+func sanctionError(sanction *models.Sanction) *requestError {
+	message := "You are muted"
+	switch {
+	case sanction.Type == models.SanctionBan:
+		message = "Your account is banned"
+	case sanction.ChannelID != nil:
+		message = "You are muted in this channel"
+	}
+
+	return &requestError{status: http.StatusForbidden, body: gin.H{
+		"error":      message,
+		"reason":     sanction.Reason,
+		"expires_at": sanction.ExpiresAt,
+	}}
+}
+
+// canSanction reports whether a user may mute, ban or lift the sanctions of another.
+// Only admins can sanction moderators, and admins cannot be sanctioned.
+func canSanction(actor *models.User, target *models.User) bool {
+	if actor.ID == target.ID || target.IsAdmin() {
+		return false
+	}
+	return actor.IsAdmin() || !target.IsModerator()
+}
+
+// newSanction builds a sanction lasting durationSeconds, permanent when 0
+func newSanction(sanctionType string, target *models.User, actor *models.User, reason string, durationSeconds int) models.Sanction {
+	sanction := models.Sanction{
+		UserID:     target.ID,
+		Type:       sanctionType,
+		Reason:     reason,
+		IssuedByID: actor.ID,
+	}
+	if durationSeconds > 0 {
+		expiresAt := time.Now().Add(time.Duration(durationSeconds) * time.Second)
+		sanction.ExpiresAt = &expiresAt
+	}
+	return sanction
+}
+
+// sanctionIssued tells the sanctioned user about a new sanction. Bans close all of their connections.
+func sanctionIssued(sanction *models.Sanction) {
+	if Hub == nil {
+		return
+	}
+
+	details := gin.H{
+		"id":         sanction.ID,
+		"type":       sanction.Type,
+		"channel_id": sanction.ChannelID,
+		"reason":     sanction.Reason,
+		"expires_at": sanction.ExpiresAt,
+	}
+
+	if sanction.Type == models.SanctionBan {
+		Hub.DisconnectUser(sanction.UserID, ws.Event{Type: ws.EventBanned, Data: details})
+		return
+	}
+
+	var channelID uint
+	if sanction.ChannelID != nil {
+		channelID = *sanction.ChannelID
+	}
+	Hub.SendToUser(sanction.UserID, ws.Event{
+		Type:      ws.EventSanctioned,
+		ChannelID: channelID,
+		Data:      details,
+	})
+}
+
+// CreateSanction mutes or bans a user (moderator only). Mutes can be limited to a channel.
+func CreateSanction(c *gin.Context) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	var req CreateSanctionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var target models.User
+	if err := config.DB.First(&target, req.UserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if !canSanction(user, &target) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot sanction this user"})
+		return
+	}
+
+	sanction := newSanction(req.Type, &target, user, req.Reason, req.DurationSeconds)
+
+	if req.ChannelID != nil {
+		if req.Type == models.SanctionBan {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Bans cannot be limited to a channel"})
+			return
+		}
+		channel, ok := loadAccessibleChannel(c, user, *req.ChannelID)
+		if !ok {
+			return
+		}
+		sanction.ChannelID = &channel.ID
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&sanction).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, user.ID, auditEntry{
+			Action:     models.AuditSanctionCreated,
+			TargetType: models.AuditTargetSanction,
+			TargetID:   sanction.ID,
+			After:      sanction,
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create sanction"})
+		return
+	}
+
+	sanctionIssued(&sanction)
+
+	config.DB.Preload("User").Preload("IssuedBy").First(&sanction, sanction.ID)
+
+	c.JSON(http.StatusCreated, sanction.ToResponse())
+}
+
+// GetSanctions lists the active sanctions, newest first (moderator only)
+func GetSanctions(c *gin.Context) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	// Channel mutes are only listed to moderators who can see the channel
+	query := config.DB.Preload("User").Preload("IssuedBy").Scopes(models.ActiveSanctions)
+	if !user.IsAdmin() {
+		channels := config.DB.Model(&models.Channel{}).Select("channels.id").Scopes(accessibleChannels(user))
+		query = query.Where("(sanctions.channel_id IS NULL OR sanctions.channel_id IN (?))", channels)
+	}
+
+	if raw := c.Query("user_id"); raw != "" {
+		userID, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
+			return
+		}
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if raw := c.Query("channel_id"); raw != "" {
+		channelID, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channel_id"})
+			return
+		}
+		query = query.Where("channel_id = ?", channelID)
+	}
+
+	if sanctionType := c.Query("type"); sanctionType != "" {
+		if sanctionType != models.SanctionMute && sanctionType != models.SanctionBan {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type, expected mute or ban"})
+			return
+		}
+		query = query.Where("type = ?", sanctionType)
+	}
+
+	var sanctions []models.Sanction
+	if err := query.Order("id desc").Find(&sanctions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sanctions"})
+		return
+	}
+
+	responses := []models.SanctionResponse{}
+	for _, sanction := range sanctions {
+		responses = append(responses, sanction.ToResponse())
+	}
+
+	c.JSON(http.StatusOK, responses)
+}
+
+// LiftSanction ends an active sanction before it expires (moderator only)
+func LiftSanction(c *gin.Context) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var sanction models.Sanction
+	if err := config.DB.Preload("User").Preload("IssuedBy").First(&sanction, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sanction not found"})
+		return
+	}
+
+	if sanction.ChannelID != nil && !user.IsAdmin() {
+		var channel models.Channel
+		if err := config.DB.First(&channel, *sanction.ChannelID).Error; err == nil && !canAccessChannel(user, &channel) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Sanction not found"})
+			return
+		}
+	}
+
+	if !sanction.IsActive() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Sanction is no longer active"})
+		return
+	}
+
+	if !canSanction(user, &sanction.User) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot lift the sanctions of this user"})
+		return
+	}
+
+	now := time.Now()
+	before := sanction
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Sanction{}).
+			Where("id = ? AND lifted_at IS NULL", sanction.ID).
+			Updates(map[string]interface{}{"lifted_at": now, "lifted_by_id": user.ID})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errSanctionLifted
+		}
+		sanction.LiftedAt = &now
+		sanction.LiftedByID = &user.ID
+
+		return recordAudit(tx, c, user.ID, auditEntry{
+			Action:     models.AuditSanctionLifted,
+			TargetType: models.AuditTargetSanction,
+			TargetID:   sanction.ID,
+			Before:     before,
+			After:      sanction,
+		})
+	})
+	if errors.Is(err, errSanctionLifted) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Sanction is no longer active"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lift sanction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, sanction.ToResponse())
+}
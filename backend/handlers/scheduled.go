@@ -0,0 +1,538 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"pictorial-backend/config"
+	"pictorial-backend/models"
+	ws "pictorial-backend/websocket"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// How far in the future messages can be scheduled
+	maxScheduleAhead = 90 * 24 * time.Hour
+
+	// Maximum number of pending scheduled messages per user
+	maxPendingScheduled = 100
+
+	// Longest time the scheduler sleeps without checking the queue
+	schedulerMaxWait = time.Minute
+
+	// Shortest time the scheduler sleeps between two checks of the queue
+	schedulerMinWait = time.Second
+
+	// A message hitting a temporary error is retried after this delay, doubled on each attempt,
+	// and marked as failed after schedulerMaxAttempts attempts
+	schedulerRetryDelay  = 30 * time.Second
+	schedulerMaxAttempts = 5
+
+	// Number of due messages published per batch
+	schedulerBatchSize = 20
+)
+
+// Wakes the scheduler when a message is scheduled or rescheduled
+var schedulerWake = make(chan struct{}, 1)
+
+// errScheduledMessageTaken is returned when a scheduled message was sent or cancelled while publishing it
+var errScheduledMessageTaken = errors.New("scheduled message no longer pending")
+
+// UpdateScheduledMessageRequest represents the scheduled message edit request. Omitted fields are kept,
+// an empty content or image_data removes the text or drawing.
+type UpdateScheduledMessageRequest struct {
+	Content   *string    `json:"content"`
+	ImageData *string    `json:"image_data"` // Base64 encoded image
+	NbOfLines *int       `json:"nb_of_lines" binding:"omitempty,min=1,max=5"`
+	SendAt    *time.Time `json:"send_at"`
+}
+
+// wakeScheduler makes the scheduler look at the queue again without blocking
+func wakeScheduler() {
+	select {
+	case schedulerWake <- struct{}{}:
+	default:
+	}
+}
+
+// validateSendAt checks that a scheduled time is in the allowed range
+func validateSendAt(sendAt time.Time) string {
+	if !sendAt.After(time.Now()) {
+		return "send_at must be in the future"
+	}
+	if sendAt.After(time.Now().Add(maxScheduleAhead)) {
+		return fmt.Sprintf("Messages can be scheduled at most %d days ahead", int(maxScheduleAhead.Hours()/24))
+	}
+	return ""
+}
+
+// scheduleMessage stores a validated message to be posted at sendAt instead of posting it now.
+// The idempotency key, if any, is remembered with the scheduled message.
+func scheduleMessage(c *gin.Context, user *models.User, message *models.Message, sendAt time.Time, ttlSeconds *int, idempotencyKey string) {
+	if msg := validateSendAt(sendAt); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var pending int64
+	if err := config.DB.Model(&models.ScheduledMessage{}).
+		Where("user_id = ? AND status = ?", message.UserID, models.ScheduledStatusPending).
+		Count(&pending).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count scheduled messages"})
+		return
+	}
+	if pending >= maxPendingScheduled {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("You can have at most %d scheduled messages", maxPendingScheduled)})
+		return
+	}
+
+	scheduled := models.ScheduledMessage{
+		ChannelID:  message.ChannelID,
+		UserID:     message.UserID,
+		ParentID:   message.ParentID,
+		Content:    message.Content,
+		Image:      message.Image,
+		ImageKey:   message.ImageKey,
+		NbOfLines:  message.NbOfLines,
+		TTLSeconds: ttlSeconds,
+		SendAt:     sendAt,
+		Status:     models.ScheduledStatusPending,
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&scheduled).Error; err != nil {
+			return err
+		}
+		if idempotencyKey == "" {
+			return nil
+		}
+		return tx.Create(&models.IdempotencyKey{UserID: user.ID, Key: idempotencyKey, ScheduledMessageID: &scheduled.ID}).Error
+	})
+	if err != nil {
+		// A concurrent request with the same key won the race
+		if idempotencyKey != "" && replayIdempotentMessage(c, user, idempotencyKey) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to schedule message"})
+		return
+	}
+
+	wakeScheduler()
+
+	c.JSON(http.StatusAccepted, scheduled.ToResponse())
+}
+
+// GetScheduledMessages lists the current user's pending and failed scheduled messages, soonest first
+func GetScheduledMessages(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var scheduled []models.ScheduledMessage
+	if err := config.DB.
+		Where("user_id = ? AND status IN ?", userID, []string{models.ScheduledStatusPending, models.ScheduledStatusFailed}).
+		Order("send_at").
+		Find(&scheduled).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scheduled messages"})
+		return
+	}
+
+	responses := []models.ScheduledMessageResponse{}
+	for _, s := range scheduled {
+		responses = append(responses, s.ToResponse())
+	}
+
+	c.JSON(http.StatusOK, responses)
+}
+
+// loadOwnScheduledMessage fetches a scheduled message of the current user that was not sent yet
+func loadOwnScheduledMessage(c *gin.Context, userID uint) (*models.ScheduledMessage, bool) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return nil, false
+	}
+
+	var scheduled models.ScheduledMessage
+	if err := config.DB.Where("user_id = ?", userID).First(&scheduled, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scheduled message not found"})
+		return nil, false
+	}
+
+	if scheduled.Status == models.ScheduledStatusSent {
+		c.JSON(http.StatusConflict, gin.H{"error": "Scheduled message was already sent"})
+		return nil, false
+	}
+
+	return &scheduled, true
+}
+
+// UpdateScheduledMessage edits a scheduled message before it is sent. Editing a failed message schedules it again.
+func UpdateScheduledMessage(c *gin.Context) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	scheduled, ok := loadOwnScheduledMessage(c, user.ID)
+	if !ok {
+		return
+	}
+
+	var req UpdateScheduledMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	previousStatus := scheduled.Status
+
+	if req.Content != nil {
+		scheduled.Content = req.Content
+		if *req.Content == "" {
+			scheduled.Content = nil
+		}
+	}
+	var imageBytes []byte
+	if req.ImageData != nil {
+		scheduled.Image = nil
+		scheduled.ImageKey = nil
+		if *req.ImageData != "" {
+			var reqErr *requestError
+			if imageBytes, reqErr = decodeDrawing(*req.ImageData); reqErr != nil {
+				reqErr.respond(c)
+				return
+			}
+		}
+	}
+	if req.NbOfLines != nil {
+		scheduled.NbOfLines = *req.NbOfLines
+	}
+	if req.SendAt != nil {
+		if msg := validateSendAt(*req.SendAt); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		scheduled.SendAt = *req.SendAt
+	}
+
+	hasContent := scheduled.Content != nil && *scheduled.Content != ""
+	hasImage := imageBytes != nil || scheduled.ImageKey != nil || len(scheduled.Image) > 0
+	if !hasContent && !hasImage {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Message must have at least one of content or image"})
+		return
+	}
+
+	// A new drawing, or fewer lines, must still fit the canvas
+	if hasImage && (req.ImageData != nil || req.NbOfLines != nil) {
+		drawing := imageBytes
+		if drawing == nil {
+			var err error
+			if drawing, err = readDrawing(scheduled.ImageKey, scheduled.Image); err != nil {
+				log.Printf("Failed to read drawing of scheduled message %d: %v", scheduled.ID, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read image"})
+				return
+			}
+		}
+		if reqErr := validateDrawing(drawing, scheduled.NbOfLines); reqErr != nil {
+			reqErr.respond(c)
+			return
+		}
+	}
+
+	channel, ok := loadAccessibleChannel(c, user, scheduled.ChannelID)
+	if !ok {
+		return
+	}
+	if reqErr := checkChannelRules(channel, user, hasContent, hasImage, scheduled.NbOfLines); reqErr != nil {
+		reqErr.respond(c)
+		return
+	}
+	if _, reqErr := messageTTL(channel, scheduled.TTLSeconds); reqErr != nil {
+		reqErr.respond(c)
+		return
+	}
+	if req.Content != nil {
+		if _, reqErr := filterMessageContent(channel.ID, scheduled.Content); reqErr != nil {
+			reqErr.respond(c)
+			return
+		}
+		if reqErr := checkLinks(user, scheduled.Content); reqErr != nil {
+			reqErr.respond(c)
+			return
+		}
+	}
+
+	if imageBytes != nil {
+		key, err := storeDrawing(imageBytes)
+		if err != nil {
+			log.Printf("Failed to store drawing: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+			return
+		}
+		scheduled.ImageKey = &key
+	}
+
+	scheduled.Status = models.ScheduledStatusPending
+	scheduled.LastError = ""
+	scheduled.Attempts = 0
+	scheduled.RetryAt = nil
+
+	// Only update the message if the scheduler did not pick it up in the meantime
+	result := config.DB.Model(scheduled).
+		Where("status = ?", previousStatus).
+		Select("content", "image", "image_key", "nb_of_lines", "send_at", "status", "last_error", "attempts", "retry_at", "updated_at").
+		Updates(scheduled)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update scheduled message"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Scheduled message was already sent"})
+		return
+	}
+
+	wakeScheduler()
+
+	c.JSON(http.StatusOK, scheduled.ToResponse())
+}
+
+// CancelScheduledMessage deletes a scheduled message before it is sent
+func CancelScheduledMessage(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	scheduled, ok := loadOwnScheduledMessage(c, userID.(uint))
+	if !ok {
+		return
+	}
+
+	result := config.DB.Where("status <> ?", models.ScheduledStatusSent).Delete(scheduled)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel scheduled message"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Scheduled message was already sent"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Scheduled message cancelled successfully"})
+}
+
+// RunMessageScheduler publishes scheduled messages when they are due. The queue lives in the
+// database, so pending messages survive restarts and messages due during downtime are sent on startup.
+func RunMessageScheduler() {
+	for {
+		for {
+			published, err := publishDueScheduledMessages()
+			if err != nil {
+				log.Printf("Message scheduler error: %v", err)
+				break
+			}
+			if published < schedulerBatchSize {
+				break
+			}
+		}
+
+		// Sleep until the next message is due, checking the queue at least every schedulerMaxWait
+		// and at most every schedulerMinWait, so that failing messages cannot keep the loop busy
+		wait := schedulerMaxWait
+		var next struct{ DueAt *time.Time }
+		if err := config.DB.Model(&models.ScheduledMessage{}).
+			Select("MIN(COALESCE(retry_at, send_at)) AS due_at").
+			Where("status = ?", models.ScheduledStatusPending).
+			Scan(&next).Error; err == nil && next.DueAt != nil {
+			if until := time.Until(*next.DueAt); until < wait {
+				wait = until
+			}
+		}
+		if wait < schedulerMinWait {
+			wait = schedulerMinWait
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-schedulerWake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// publishDueScheduledMessages publishes a batch of due messages, returning how many were due
+func publishDueScheduledMessages() (int, error) {
+	var due []models.ScheduledMessage
+	if err := config.DB.
+		Where("status = ? AND send_at <= ?", models.ScheduledStatusPending, time.Now()).
+		Where("retry_at IS NULL OR retry_at <= ?", time.Now()).
+		Order("send_at").
+		Limit(schedulerBatchSize).
+		Find(&due).Error; err != nil {
+		return 0, err
+	}
+
+	for i := range due {
+		publishScheduledMessage(&due[i])
+	}
+	return len(due), nil
+}
+
+// publishScheduledMessage posts a due message, checking the channel rules as they are now
+func publishScheduledMessage(scheduled *models.ScheduledMessage) {
+	var user models.User
+	if err := config.DB.First(&user, scheduled.UserID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		failScheduledMessage(scheduled, "The author no longer exists")
+		return
+	} else if err != nil {
+		log.Printf("Failed to load the author of scheduled message %d: %v", scheduled.ID, err)
+		retryScheduledMessage(scheduled)
+		return
+	}
+
+	if sanction, err := activeSanction(user.ID, scheduled.ChannelID, models.SanctionMute, models.SanctionBan); err != nil {
+		log.Printf("Failed to check sanctions for scheduled message %d: %v", scheduled.ID, err)
+		retryScheduledMessage(scheduled)
+		return
+	} else if sanction != nil {
+		failScheduledMessage(scheduled, fmt.Sprint(sanctionError(sanction).body["error"]))
+		return
+	}
+
+	var channel models.Channel
+	if err := config.DB.First(&channel, scheduled.ChannelID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		failScheduledMessage(scheduled, "The channel was deleted")
+		return
+	} else if err != nil {
+		log.Printf("Failed to load the channel of scheduled message %d: %v", scheduled.ID, err)
+		retryScheduledMessage(scheduled)
+		return
+	}
+	if !canAccessChannel(&user, &channel) {
+		failScheduledMessage(scheduled, "You can no longer access this channel")
+		return
+	}
+
+	hasContent := scheduled.Content != nil && *scheduled.Content != ""
+	hasImage := scheduled.ImageKey != nil || len(scheduled.Image) > 0
+	if reqErr := checkChannelRules(&channel, &user, hasContent, hasImage, scheduled.NbOfLines); reqErr != nil {
+		failScheduledMessage(scheduled, fmt.Sprint(reqErr.body["error"]))
+		return
+	}
+	ttl, reqErr := messageTTL(&channel, scheduled.TTLSeconds)
+	if reqErr != nil {
+		failScheduledMessage(scheduled, fmt.Sprint(reqErr.body["error"]))
+		return
+	}
+
+	message := models.Message{
+		ChannelID: scheduled.ChannelID,
+		UserID:    scheduled.UserID,
+		Content:   scheduled.Content,
+		Image:     scheduled.Image,
+		ImageKey:  scheduled.ImageKey,
+		NbOfLines: scheduled.NbOfLines,
+	}
+	setMessageExpiry(&message, ttl)
+
+	// The word filter may have changed since the message was scheduled
+	flagged, reqErr := filterMessageContent(channel.ID, message.Content)
+	if reqErr != nil {
+		if reqErr.status == http.StatusInternalServerError {
+			log.Printf("Failed to filter scheduled message %d", scheduled.ID)
+			retryScheduledMessage(scheduled)
+			return
+		}
+		failScheduledMessage(scheduled, fmt.Sprint(reqErr.body["error"]))
+		return
+	}
+
+	// A reply whose thread was deleted is posted in the channel
+	if scheduled.ParentID != nil {
+		var parent models.Message
+		if err := config.DB.First(&parent, *scheduled.ParentID).Error; err == nil {
+			message.ParentID = scheduled.ParentID
+		}
+	}
+
+	// Marking the scheduled message as sent in the same transaction posts it exactly once,
+	// even if another instance or a cancellation races with this one
+	_, err := publishMessageTx(&message, "", func(tx *gorm.DB) error {
+		result := tx.Model(&models.ScheduledMessage{}).
+			Where("id = ? AND status = ?", scheduled.ID, models.ScheduledStatusPending).
+			Updates(map[string]interface{}{
+				"status":     models.ScheduledStatusSent,
+				"message_id": message.ID,
+				"last_error": "",
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errScheduledMessageTaken
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errScheduledMessageTaken):
+	case err != nil:
+		log.Printf("Failed to publish scheduled message %d: %v", scheduled.ID, err)
+		retryScheduledMessage(scheduled)
+	case len(flagged) > 0:
+		flagMessage(&message, flagged)
+	}
+}
+
+// retryScheduledMessage postpones the next attempt at a message that hit a temporary error,
+// or marks it as failed once it ran out of attempts
+func retryScheduledMessage(scheduled *models.ScheduledMessage) {
+	attempts := scheduled.Attempts + 1
+	if attempts >= schedulerMaxAttempts {
+		failScheduledMessage(scheduled, "The message could not be posted")
+		return
+	}
+
+	retryAt := time.Now().Add(schedulerRetryDelay << (attempts - 1))
+	if err := config.DB.Model(scheduled).
+		Where("status = ?", models.ScheduledStatusPending).
+		UpdateColumns(map[string]interface{}{"attempts": attempts, "retry_at": retryAt}).Error; err != nil {
+		log.Printf("Failed to postpone scheduled message %d: %v", scheduled.ID, err)
+	}
+}
+
+// failScheduledMessage marks a scheduled message as failed and tells its author
+func failScheduledMessage(scheduled *models.ScheduledMessage, reason string) {
+	result := config.DB.Model(scheduled).
+		Where("status = ?", models.ScheduledStatusPending).
+		Updates(map[string]interface{}{
+			"status":     models.ScheduledStatusFailed,
+			"last_error": reason,
+		})
+	if result.Error != nil {
+		log.Printf("Failed to mark scheduled message %d as failed: %v", scheduled.ID, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		return
+	}
+
+	scheduled.Status = models.ScheduledStatusFailed
+	scheduled.LastError = reason
+
+	if Hub != nil {
+		Hub.SendToUser(scheduled.UserID, ws.Event{
+			Type:      ws.EventScheduledMessageFailed,
+			ChannelID: scheduled.ChannelID,
+			Data:      scheduled.ToResponse(),
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"pictorial-backend/config"
+	"pictorial-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Maximum length of a search query
+const maxSearchQueryLength = 200
+
+// Highlighted terms are wrapped in <mark> tags, the rest of the text is returned as is
+const searchHeadlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5, FragmentDelimiter=\" … \""
+
+// SearchResult represents a message matching a search, with the matching terms highlighted
+type SearchResult struct {
+	models.MessageResponse
+	Highlight string `json:"highlight"`
+}
+
+// SearchPage represents a page of search results, newest first, paginated like MessagePage
+type SearchPage struct {
+	Results    []SearchResult `json:"results"`
+	HasMore    bool           `json:"has_more"`
+	NextCursor *uint          `json:"next_cursor"`
+	PrevCursor *uint          `json:"prev_cursor"`
+}
+
+// parseSearchTime reads a date range bound, either RFC 3339 or a date (YYYY-MM-DD).
+// A date used as the end of the range includes the whole day.
+func parseSearchTime(value string, endOfRange bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	day, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfRange {
+		return day.AddDate(0, 0, 1), nil
+	}
+	return day, nil
+}
+
+// SearchMessages searches the content of the messages the user can read
+func SearchMessages(c *gin.Context) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Search query is required"})
+		return
+	}
+	if len(query) > maxSearchQueryLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Search query is limited to %d characters", maxSearchQueryLength)})
+		return
+	}
+
+	tsQuery := fmt.Sprintf("websearch_to_tsquery('%s', ?)", config.SearchLanguage)
+	conditions := []func(*gorm.DB) *gorm.DB{
+		inAccessibleChannels(user),
+		func(db *gorm.DB) *gorm.DB {
+			return db.Where("messages.content_tsv @@ "+tsQuery, query)
+		},
+	}
+
+	if raw := c.Query("channel_id"); raw != "" {
+		channelID, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channel_id"})
+			return
+		}
+		if _, ok := loadAccessibleChannel(c, user, uint(channelID)); !ok {
+			return
+		}
+		conditions = append(conditions, func(db *gorm.DB) *gorm.DB {
+			return db.Where("messages.channel_id = ?", channelID)
+		})
+	}
+
+	if raw := c.Query("author_id"); raw != "" {
+		authorID, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid author_id"})
+			return
+		}
+		conditions = append(conditions, func(db *gorm.DB) *gorm.DB {
+			return db.Where("messages.user_id = ?", authorID)
+		})
+	}
+
+	if raw := c.Query("from"); raw != "" {
+		from, err := parseSearchTime(raw, false)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from, expected a date (YYYY-MM-DD) or an RFC 3339 time"})
+			return
+		}
+		conditions = append(conditions, func(db *gorm.DB) *gorm.DB {
+			return db.Where("messages.created_at >= ?", from)
+		})
+	}
+
+	if raw := c.Query("to"); raw != "" {
+		to, err := parseSearchTime(raw, true)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to, expected a date (YYYY-MM-DD) or an RFC 3339 time"})
+			return
+		}
+		conditions = append(conditions, func(db *gorm.DB) *gorm.DB {
+			return db.Where("messages.created_at < ?", to)
+		})
+	}
+
+	if raw := c.Query("has_drawing"); raw != "" {
+		wantsDrawing, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid has_drawing"})
+			return
+		}
+		conditions = append(conditions, func(db *gorm.DB) *gorm.DB {
+			if wantsDrawing {
+				return db.Where(hasDrawing)
+			}
+			return db.Where("NOT " + hasDrawing)
+		})
+	}
+
+	req, ok := parsePageRequest(c)
+	if !ok {
+		return
+	}
+
+	page, err := paginateMessages(func(db *gorm.DB) *gorm.DB {
+		for _, condition := range conditions {
+			db = condition(db)
+		}
+		return db
+	}, req, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search messages"})
+		return
+	}
+
+	results := SearchPage{
+		Results:    []SearchResult{},
+		HasMore:    page.HasMore,
+		NextCursor: page.NextCursor,
+		PrevCursor: page.PrevCursor,
+	}
+	if len(page.Messages) == 0 {
+		c.JSON(http.StatusOK, results)
+		return
+	}
+
+	// Highlight only the messages of the page
+	ids := make([]uint, len(page.Messages))
+	for i, msg := range page.Messages {
+		ids[i] = msg.ID
+	}
+
+	var headlines []struct {
+		ID        uint
+		Highlight string
+	}
+	if err := config.DB.Model(&models.Message{}).
+		Select(fmt.Sprintf("messages.id, ts_headline('%s', coalesce(messages.content, ''), %s, ?) AS highlight", config.SearchLanguage, tsQuery), query, searchHeadlineOptions).
+		Where("messages.id IN ?", ids).
+		Scan(&headlines).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to highlight results"})
+		return
+	}
+
+	highlights := make(map[uint]string, len(headlines))
+	for _, headline := range headlines {
+		highlights[headline.ID] = headline.Highlight
+	}
+
+	for _, msg := range page.Messages {
+		results.Results = append(results.Results, SearchResult{
+			MessageResponse: msg,
+			Highlight:       highlights[msg.ID],
+		})
+	}
+
+	c.JSON(http.StatusOK, results)
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"net/http"
+
+	"pictorial-backend/config"
+	"pictorial-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// shadowBannedUserIDs selects the IDs of the shadow-banned users
+func shadowBannedUserIDs() *gorm.DB {
+	return config.DB.Model(&models.User{}).Select("id").Where("shadow_banned = ?", true)
+}
+
+// notShadowBanned leaves out the messages of shadow-banned users
+func notShadowBanned(db *gorm.DB) *gorm.DB {
+	return db.Where("messages.user_id NOT IN (?)", shadowBannedUserIDs())
+}
+
+// visibleTo leaves out the messages of shadow-banned users, except for their authors.
+// Moderators see every message.
+func visibleTo(viewer *models.User) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if viewer.IsModerator() {
+			return db
+		}
+		return db.Where("(messages.user_id = ? OR messages.user_id NOT IN (?))", viewer.ID, shadowBannedUserIDs())
+	}
+}
+
+// reactionsVisibleTo leaves out the reactions of shadow-banned users, except for the viewer's own
+func reactionsVisibleTo(viewerID uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("(reactions.user_id = ? OR reactions.user_id NOT IN (?))", viewerID, shadowBannedUserIDs())
+	}
+}
+
+// setShadowBan shadow-bans or reinstates a user, writing the response
+func setShadowBan(c *gin.Context, shadowBanned bool) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var target models.User
+	if err := config.DB.First(&target, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if !canSanction(user, &target) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot sanction this user"})
+		return
+	}
+
+	if target.ShadowBanned == shadowBanned {
+		if shadowBanned {
+			c.JSON(http.StatusConflict, gin.H{"error": "User is already shadow-banned"})
+		} else {
+			c.JSON(http.StatusConflict, gin.H{"error": "User is not shadow-banned"})
+		}
+		return
+	}
+
+	action := models.AuditUserShadowBanned
+	if !shadowBanned {
+		action = models.AuditUserShadowBanLifted
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&target).UpdateColumn("shadow_banned", shadowBanned).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, user.ID, auditEntry{
+			Action:     action,
+			TargetType: models.AuditTargetUser,
+			TargetID:   target.ID,
+			Before:     gin.H{"shadow_banned": !shadowBanned},
+			After:      gin.H{"shadow_banned": shadowBanned},
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": target.ToResponse(), "shadow_banned": shadowBanned})
+}
+
+// ShadowBanUser hides the messages of a user from everyone else, without telling them (moderator only)
+func ShadowBanUser(c *gin.Context) {
+	setShadowBan(c, true)
+}
+
+// LiftShadowBan makes the messages of a shadow-banned user visible again (moderator only)
+func LiftShadowBan(c *gin.Context) {
+	setShadowBan(c, false)
+}
+
+// GetShadowBannedUsers lists the shadow-banned users (moderator only)
+func GetShadowBannedUsers(c *gin.Context) {
+	var users []models.User
+	if err := config.DB.Where("shadow_banned = ?", true).Order("name").Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch shadow-banned users"})
+		return
+	}
+
+	responses := []models.UserResponse{}
+	for _, user := range users {
+		responses = append(responses, user.ToResponse())
+	}
+
+	c.JSON(http.StatusOK, responses)
+}
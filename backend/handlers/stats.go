@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"pictorial-backend/config"
+	"pictorial-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// Default and maximum statistics window in days
+	defaultStatsDays = 30
+	maxStatsDays     = 365
+
+	// Number of top posters reported
+	topPostersLimit = 10
+)
+
+// MessageCounts holds aggregate message counts
+type MessageCounts struct {
+	TotalMessages   int64 `json:"total_messages"`
+	TextMessages    int64 `json:"text_messages"`
+	DrawingMessages int64 `json:"drawing_messages"`
+	UniquePosters   int64 `json:"unique_posters"`
+}
+
+// DailyCount is the number of messages posted on a given day
+type DailyCount struct {
+	Day   time.Time `json:"day"`
+	Count int64     `json:"count"`
+}
+
+// PosterCount is the number of messages posted by a user
+type PosterCount struct {
+	UserID uint   `json:"user_id"`
+	Name   string `json:"name"`
+	Count  int64  `json:"count"`
+}
+
+// ChannelStats represents the activity report of a single channel
+type ChannelStats struct {
+	ChannelID uint `json:"channel_id"`
+	MessageCounts
+	Since          time.Time     `json:"since"`
+	Days           int           `json:"days"`
+	LiveOccupants  []uint        `json:"live_occupants"`
+	MessagesPerDay []DailyCount  `json:"messages_per_day"`
+	TopPosters     []PosterCount `json:"top_posters"`
+}
+
+// ChannelSummary represents a channel's line in the server-wide report
+type ChannelSummary struct {
+	ChannelID   uint   `json:"channel_id"`
+	ChannelName string `json:"channel_name"`
+	MessageCounts
+	LastMessageAt *time.Time `json:"last_message_at"`
+	LiveOccupants int        `json:"live_occupants"`
+}
+
+// ServerStats represents the activity report across all channels
+type ServerStats struct {
+	MessageCounts
+	Since          time.Time        `json:"since"`
+	Days           int              `json:"days"`
+	OnlineUsers    int              `json:"online_users"`
+	Channels       []ChannelSummary `json:"channels"`
+	MessagesPerDay []DailyCount     `json:"messages_per_day"`
+	TopPosters     []PosterCount    `json:"top_posters"`
+}
+
+// Aggregate columns shared by the statistics queries
+const messageCountsSelect = "COUNT(*) AS total_messages, " +
+	"COUNT(*) FILTER (WHERE NOT " + hasDrawing + ") AS text_messages, " +
+	"COUNT(*) FILTER (WHERE " + hasDrawing + ") AS drawing_messages, " +
+	"COUNT(DISTINCT messages.user_id) AS unique_posters"
+
+// statsWindow parses the days query parameter and returns the window start
+func statsWindow(c *gin.Context) (int, time.Time) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(defaultStatsDays)))
+	if err != nil || days < 1 {
+		days = defaultStatsDays
+	}
+	if days > maxStatsDays {
+		days = maxStatsDays
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	return days, today.AddDate(0, 0, -(days - 1))
+}
+
+// countMessages computes the aggregate counts of the messages matched by scope
+func countMessages(scope func(*gorm.DB) *gorm.DB) (MessageCounts, error) {
+	var counts MessageCounts
+	err := config.DB.Model(&models.Message{}).
+		Scopes(scope).
+		Select(messageCountsSelect).
+		Scan(&counts).Error
+	return counts, err
+}
+
+// messagesPerDay counts the messages matched by scope for each day of the window, including empty days
+func messagesPerDay(scope func(*gorm.DB) *gorm.DB, since time.Time, days int) ([]DailyCount, error) {
+	var rows []DailyCount
+	if err := config.DB.Model(&models.Message{}).
+		Scopes(scope).
+		Select("date_trunc('day', messages.created_at AT TIME ZONE 'UTC') AS day, COUNT(*) AS count").
+		Group("day").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	countByDay := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		countByDay[row.Day.Format("2006-01-02")] = row.Count
+	}
+
+	perDay := make([]DailyCount, days)
+	for i := range perDay {
+		day := since.AddDate(0, 0, i)
+		perDay[i] = DailyCount{Day: day, Count: countByDay[day.Format("2006-01-02")]}
+	}
+	return perDay, nil
+}
+
+// topPosters returns the users who posted the most messages matched by scope
+func topPosters(scope func(*gorm.DB) *gorm.DB) ([]PosterCount, error) {
+	posters := []PosterCount{}
+	err := config.DB.Model(&models.Message{}).
+		Scopes(scope).
+		Select("messages.user_id, users.name, COUNT(*) AS count").
+		Joins("JOIN users ON users.id = messages.user_id").
+		Group("messages.user_id, users.name").
+		Order("count desc").
+		Limit(topPostersLimit).
+		Scan(&posters).Error
+	return posters, err
+}
+
+// GetChannelStats returns the activity report of a channel (admin only)
+func GetChannelStats(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var channel models.Channel
+	if err := config.DB.First(&channel, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+		return
+	}
+
+	days, since := statsWindow(c)
+	scope := func(db *gorm.DB) *gorm.DB {
+		return db.Where("messages.channel_id = ? AND messages.created_at >= ?", channel.ID, since)
+	}
+
+	stats := ChannelStats{
+		ChannelID:     channel.ID,
+		Since:         since,
+		Days:          days,
+		LiveOccupants: []uint{},
+	}
+
+	var err error
+	if stats.MessageCounts, err = countMessages(scope); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute message counts"})
+		return
+	}
+	if stats.MessagesPerDay, err = messagesPerDay(scope, since, days); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute daily activity"})
+		return
+	}
+	if stats.TopPosters, err = topPosters(scope); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute top posters"})
+		return
+	}
+
+	if Hub != nil {
+		stats.LiveOccupants = Hub.ChannelOccupants(channel.ID)
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// GetServerStats returns the activity report across all channels (admin only)
+func GetServerStats(c *gin.Context) {
+	days, since := statsWindow(c)
+	scope := func(db *gorm.DB) *gorm.DB {
+		return db.Where("messages.created_at >= ?", since)
+	}
+
+	stats := ServerStats{
+		Since: since,
+		Days:  days,
+	}
+
+	var err error
+	if stats.MessageCounts, err = countMessages(scope); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute message counts"})
+		return
+	}
+	if stats.MessagesPerDay, err = messagesPerDay(scope, since, days); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute daily activity"})
+		return
+	}
+	if stats.TopPosters, err = topPosters(scope); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute top posters"})
+		return
+	}
+
+	// One row per channel, including channels without messages in the window
+	stats.Channels = []ChannelSummary{}
+	if err := config.DB.Model(&models.Channel{}).
+		Select("channels.id AS channel_id, channels.name AS channel_name, "+
+			"COUNT(messages.id) AS total_messages, "+
+			"COUNT(messages.id) FILTER (WHERE NOT "+hasDrawing+") AS text_messages, "+
+			"COUNT(messages.id) FILTER (WHERE "+hasDrawing+") AS drawing_messages, "+
+			"COUNT(DISTINCT messages.user_id) AS unique_posters, "+
+			"MAX(messages.created_at) AS last_message_at").
+		Joins("LEFT JOIN messages ON messages.channel_id = channels.id AND messages.deleted_at IS NULL AND messages.created_at >= ?", since).
+		Group("channels.id, channels.name").
+		Order("total_messages desc, channels.id").
+		Scan(&stats.Channels).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute channel activity"})
+		return
+	}
+
+	if Hub != nil {
+		stats.OnlineUsers = Hub.OnlineUserCount()
+		occupancy := Hub.OccupancyByChannel()
+		for i := range stats.Channels {
+			stats.Channels[i].LiveOccupants = occupancy[stats.Channels[i].ChannelID]
+		}
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
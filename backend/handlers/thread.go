@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"pictorial-backend/config"
+	"pictorial-backend/models"
+	ws "pictorial-backend/websocket"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// threadSummary holds the reply counters of a thread's parent message
+type threadSummary struct {
+	ParentID    uint       `json:"message_id"`
+	ReplyCount  int64      `json:"reply_count"`
+	LastReplyAt *time.Time `json:"last_reply_at"`
+}
+
+// threadSummaries computes the reply counters of the given parent messages.
+// Replies of shadow-banned users are not counted, the counters are the same for every viewer.
+func threadSummaries(parentIDs []uint) (map[uint]threadSummary, error) {
+	var rows []threadSummary
+	if err := config.DB.Model(&models.Message{}).
+		Select("parent_id, COUNT(*) AS reply_count, MAX(created_at) AS last_reply_at").
+		Scopes(notExpired, notShadowBanned).
+		Where("parent_id IN ?", parentIDs).
+		Group("parent_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	summaries := make(map[uint]threadSummary, len(rows))
+	for _, row := range rows {
+		summaries[row.ParentID] = row
+	}
+	return summaries, nil
+}
+
+// attachThreads fills the reply counters of message responses with a single grouped query
+func attachThreads(responses []models.MessageResponse) error {
+	if len(responses) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(responses))
+	for i := range responses {
+		ids[i] = responses[i].ID
+	}
+
+	summaries, err := threadSummaries(ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range responses {
+		if summary, ok := summaries[responses[i].ID]; ok {
+			responses[i].ReplyCount = summary.ReplyCount
+			responses[i].LastReplyAt = summary.LastReplyAt
+		}
+	}
+	return nil
+}
+
+// resolveThreadParent returns the message a reply attaches to, writing a 400 response if it is not valid.
+// Replying to a reply attaches to the thread's root, threads are a single level deep.
+func resolveThreadParent(c *gin.Context, user *models.User, parentID uint, channelID uint) (*models.Message, bool) {
+	var parent models.Message
+	if err := config.DB.Scopes(notExpired, visibleTo(user)).First(&parent, parentID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parent message not found"})
+		return nil, false
+	}
+
+	if parent.ParentID != nil {
+		if err := config.DB.Scopes(notExpired, visibleTo(user)).First(&parent, *parent.ParentID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parent message not found"})
+			return nil, false
+		}
+	}
+
+	if parent.ChannelID != channelID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parent message belongs to another channel"})
+		return nil, false
+	}
+
+	return &parent, true
+}
+
+// broadcastThreadUpdate pushes the new reply counters of a thread to the channel
+func broadcastThreadUpdate(parentID uint, channelID uint) {
+	if Hub == nil {
+		return
+	}
+
+	summaries, err := threadSummaries([]uint{parentID})
+	if err != nil {
+		log.Printf("Failed to compute thread summary for message %d: %v", parentID, err)
+		return
+	}
+
+	summary, ok := summaries[parentID]
+	if !ok {
+		summary = threadSummary{ParentID: parentID}
+	}
+
+	Hub.BroadcastToChannel(channelID, ws.Event{
+		Type:      ws.EventThreadUpdated,
+		ChannelID: channelID,
+		Data:      summary,
+	})
+}
+
+// GetMessageReplies returns a page of the replies to a message, newest first
+func GetMessageReplies(c *gin.Context) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var parent models.Message
+	if err := config.DB.Preload("Channel").Scopes(notExpired, visibleTo(user)).First(&parent, id).Error; err != nil || !canAccessChannel(user, &parent.Channel) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+
+	req, ok := parsePageRequest(c)
+	if !ok {
+		return
+	}
+
+	page, err := paginateMessages(func(db *gorm.DB) *gorm.DB {
+		return db.Where("messages.parent_id = ?", parent.ID)
+	}, req, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch replies"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
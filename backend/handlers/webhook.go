@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"pictorial-backend/config"
+	"pictorial-backend/models"
+	"pictorial-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Maximum size of a request posted to an incoming webhook
+const maxWebhookBodySize = 8 << 20
+
+// CreateWebhookRequest represents the incoming webhook creation request
+type CreateWebhookRequest struct {
+	Name string `json:"name" binding:"required,min=3,max=50"` // Name shown as the author of the messages
+}
+
+// ExecuteWebhookRequest represents a message posted to an incoming webhook, as JSON or multipart form
+type ExecuteWebhookRequest struct {
+	Content   *string `json:"content" form:"content"`
+	ImageData *string `json:"image_data" form:"image_data"` // Base64 encoded image, or an "image" file part in multipart requests
+	NbOfLines int     `json:"nb_of_lines" form:"nb_of_lines" binding:"omitempty,min=1,max=5"`
+}
+
+// CreateWebhook creates an incoming webhook for a channel (admins and channel owner)
+func CreateWebhook(c *gin.Context) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var channel models.Channel
+	if err := config.DB.First(&channel, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+		return
+	}
+
+	if !canManageChannel(user, &channel) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins and the channel owner can manage webhooks"})
+		return
+	}
+
+	var req CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := utils.RandomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate webhook token"})
+		return
+	}
+
+	// The identity has a random password nobody knows, so it cannot log in
+	password, err := utils.RandomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate webhook token"})
+		return
+	}
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	webhook := models.IncomingWebhook{
+		ChannelID: channel.ID,
+		CreatorID: user.ID,
+		TokenHash: utils.HashToken(token),
+		User: models.User{
+			Name:     req.Name,
+			Password: hashedPassword,
+			Role:     "webhook",
+		},
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&webhook.User).Error; err != nil {
+			return err
+		}
+		webhook.UserID = webhook.User.ID
+		if err := tx.Omit("User").Create(&webhook).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, user.ID, auditEntry{
+			Action:     models.AuditWebhookCreated,
+			TargetType: models.AuditTargetWebhook,
+			TargetID:   webhook.ID,
+			After:      webhook.ToResponse(),
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Name already taken"})
+		return
+	}
+
+	// The token is only ever shown here
+	response := webhook.ToResponse()
+	response.URL = fmt.Sprintf("/api/v1/hooks/%d/%s", webhook.ID, token)
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetChannelWebhooks lists the incoming webhooks of a channel (admins and channel owner)
+func GetChannelWebhooks(c *gin.Context) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var channel models.Channel
+	if err := config.DB.First(&channel, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+		return
+	}
+
+	if !canManageChannel(user, &channel) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins and the channel owner can manage webhooks"})
+		return
+	}
+
+	var channelWebhooks []models.IncomingWebhook
+	if err := config.DB.Preload("User").Where("channel_id = ?", channel.ID).Order("created_at desc").Find(&channelWebhooks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch webhooks"})
+		return
+	}
+
+	responses := []models.IncomingWebhookResponse{}
+	for _, webhook := range channelWebhooks {
+		responses = append(responses, webhook.ToResponse())
+	}
+
+	c.JSON(http.StatusOK, responses)
+}
+
+// DeleteWebhook deletes an incoming webhook (admins and channel owner)
+func DeleteWebhook(c *gin.Context) {
+	user, ok := loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var webhook models.IncomingWebhook
+	if err := config.DB.Preload("Channel").First(&webhook, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	if !canManageChannel(user, &webhook.Channel) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins and the channel owner can manage webhooks"})
+		return
+	}
+
+	// Messages keep referencing the webhook identity, so only the webhook is removed
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&webhook).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, user.ID, auditEntry{
+			Action:     models.AuditWebhookDeleted,
+			TargetType: models.AuditTargetWebhook,
+			TargetID:   webhook.ID,
+			Before:     webhook,
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// ExecuteWebhook posts a message into the webhook's channel (authenticated by the URL token).
+// Webhooks bypass the channel's posting restrictions, but not the word filter or the link limit.
+func ExecuteWebhook(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	var webhook models.IncomingWebhook
+	if err := config.DB.Preload("Channel").Preload("User").First(&webhook, id).Error; err != nil || webhook.Channel.ID == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	if subtle.ConstantTimeCompare([]byte(utils.HashToken(c.Param("token"))), []byte(webhook.TokenHash)) != 1 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBodySize)
+
+	// JSON or multipart form, depending on the Content-Type
+	var req ExecuteWebhookRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.NbOfLines == 0 {
+		req.NbOfLines = 1
+	}
+
+	message := models.Message{
+		ChannelID: webhook.ChannelID,
+		UserID:    webhook.UserID,
+		Content:   req.Content,
+		NbOfLines: req.NbOfLines,
+	}
+	setMessageExpiry(&message, webhook.Channel.DefaultMessageTTLSeconds)
+
+	// The word filter may mask the text, the link limit then counts what would be posted
+	flagged, reqErr := filterMessageContent(webhook.ChannelID, message.Content)
+	if reqErr != nil {
+		reqErr.respond(c)
+		return
+	}
+	if reqErr := checkLinks(&webhook.User, message.Content); reqErr != nil {
+		reqErr.respond(c)
+		return
+	}
+
+	if file, err := c.FormFile("image"); err == nil {
+		opened, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image data"})
+			return
+		}
+		defer opened.Close()
+
+		// One byte past the limit is enough to reject the file
+		if message.Image, err = io.ReadAll(io.LimitReader(opened, maxImageBytes+1)); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image data"})
+			return
+		}
+	} else if req.ImageData != nil && *req.ImageData != "" {
+		var reqErr *requestError
+		if message.Image, reqErr = decodeDrawing(*req.ImageData); reqErr != nil {
+			reqErr.respond(c)
+			return
+		}
+	}
+
+	if len(message.Image) > 0 {
+		if reqErr := validateDrawing(message.Image, message.NbOfLines); reqErr != nil {
+			reqErr.respond(c)
+			return
+		}
+
+		key, err := storeDrawing(message.Image)
+		if err != nil {
+			log.Printf("Failed to store drawing: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+			return
+		}
+		message.Image = nil
+		message.ImageKey = &key
+	}
+
+	// Validate that at least one of content or image is provided
+	if (message.Content == nil || *message.Content == "") && !message.HasImage() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Message must have at least one of content or image"})
+		return
+	}
+
+	response, err := publishMessage(&message)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create message"})
+		return
+	}
+
+	if len(flagged) > 0 {
+		flagMessage(&message, flagged)
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"pictorial-backend/config"
+	"pictorial-backend/models"
 	"pictorial-backend/utils"
 	ws "pictorial-backend/websocket"
 
@@ -41,6 +42,15 @@ func WSHandler(hub *ws.Hub) gin.HandlerFunc {
 
 		userID := claims.UserID
 
+		// Banned users cannot connect
+		if sanction, err := activeSanction(userID, 0, models.SanctionBan); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check sanctions"})
+			return
+		} else if sanction != nil {
+			sanctionError(sanction).respond(c)
+			return
+		}
+
 		// Upgrade HTTP connection to WebSocket
 		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 		if err != nil {
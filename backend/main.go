@@ -7,6 +7,8 @@ import (
 	"pictorial-backend/config"
 	"pictorial-backend/handlers"
 	"pictorial-backend/routes"
+	"pictorial-backend/storage"
+	"pictorial-backend/webhooks"
 	ws "pictorial-backend/websocket"
 
 	"github.com/gin-gonic/gin"
@@ -19,12 +21,47 @@ func main() {
 	// Run migrations
 	config.MigrateDB()
 
+	// Open the blob store holding the drawings
+	blobs, err := storage.New()
+	if err != nil {
+		log.Fatal("Failed to open blob store:", err)
+	}
+	handlers.Blobs = blobs
+
+	// "main migrate-images" moves the drawings stored in the database to the blob store, then exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate-images" {
+		if err := handlers.MigrateImages(); err != nil {
+			log.Fatal("Failed to migrate images:", err)
+		}
+		log.Println("Images migrated to the blob store")
+		return
+	}
+
 	// Initialize WebSocket hub
 	hub := ws.NewHub()
+	hub.SetSubscribeAuthorizer(handlers.AuthorizeSubscription)
 	handlers.Hub = hub
 	go hub.Run()
 	log.Println("WebSocket hub started")
 
+	// Initialize outgoing webhook delivery
+	dispatcher := webhooks.NewDispatcher(config.DB)
+	handlers.Webhooks = dispatcher
+	go dispatcher.Run()
+	log.Println("Webhook dispatcher started")
+
+	// Publish scheduled messages when they are due
+	go handlers.RunMessageScheduler()
+	log.Println("Message scheduler started")
+
+	// Delete messages when their time-to-live passes
+	go handlers.RunMessageSweeper()
+	log.Println("Message sweeper started")
+
+	// Delete the drawings nothing refers to anymore
+	go handlers.RunBlobCollector()
+	log.Println("Blob collector started")
+
 	// Set Gin mode
 	if os.Getenv("GIN_MODE") == "" {
 		gin.SetMode(gin.DebugMode)
@@ -33,12 +70,17 @@ func main() {
 	// Create router
 	router := gin.Default()
 
+	// Client IPs, recorded in the audit log, only come from X-Forwarded-For behind a trusted proxy
+	if err := router.SetTrustedProxies(config.GetTrustedProxies()); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
+
 	// CORS middleware
 	router.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, Idempotency-Key")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
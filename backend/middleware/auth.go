@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"pictorial-backend/config"
+	"pictorial-backend/models"
 	"pictorial-backend/utils"
 
 	"github.com/gin-gonic/gin"
@@ -36,6 +37,22 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// Tokens issued before a ban stop working right away
+		var bans int64
+		if err := config.DB.Model(&models.Sanction{}).
+			Scopes(models.ActiveSanctions).
+			Where("user_id = ? AND type = ?", claims.UserID, models.SanctionBan).
+			Count(&bans).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check sanctions"})
+			c.Abort()
+			return
+		}
+		if bans > 0 {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Your account is banned"})
+			c.Abort()
+			return
+		}
+
 		// Set user ID in context for use in handlers
 		c.Set("userID", claims.UserID)
 		c.Set("username", claims.Username)
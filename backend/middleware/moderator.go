@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+
+	"pictorial-backend/config"
+	"pictorial-backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ModeratorMiddleware checks if the user has moderator or admin role
+func ModeratorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.Abort()
+			return
+		}
+
+		var user models.User
+		if err := config.DB.First(&user, userID).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			c.Abort()
+			return
+		}
+
+		if !user.IsModerator() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Moderator access required"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,95 @@
+package models
+
+import "time"
+
+// Audited actions
+const (
+	AuditChannelCreated         = "channel.created"
+	AuditChannelUpdated         = "channel.updated"
+	AuditChannelDeleted         = "channel.deleted"
+	AuditChannelTopicUpdated    = "channel.topic_updated"
+	AuditMessageDeleted         = "message.deleted" // By someone other than the author
+	AuditMessagePinned          = "message.pinned"
+	AuditMessageUnpinned        = "message.unpinned"
+	AuditMessageRestored        = "message.restored"
+	AuditMessageHardDeleted     = "message.hard_deleted"
+	AuditMessagesPurged         = "messages.purged"
+	AuditReportResolved         = "report.resolved"
+	AuditSanctionCreated        = "sanction.created"
+	AuditSanctionLifted         = "sanction.lifted"
+	AuditUserShadowBanned       = "user.shadow_banned"
+	AuditUserShadowBanLifted    = "user.shadow_ban_lifted"
+	AuditFilterRuleCreated      = "filter_rule.created"
+	AuditFilterRuleDeleted      = "filter_rule.deleted"
+	AuditStampCreated           = "stamp.created"
+	AuditStampDeleted           = "stamp.deleted"
+	AuditInviteCreated          = "invite.created"
+	AuditInviteRevoked          = "invite.revoked"
+	AuditWebhookCreated         = "webhook.created"
+	AuditWebhookDeleted         = "webhook.deleted"
+	AuditOutgoingWebhookCreated = "outgoing_webhook.created"
+	AuditOutgoingWebhookUpdated = "outgoing_webhook.updated"
+	AuditOutgoingWebhookDeleted = "outgoing_webhook.deleted"
+	AuditWebhookRedelivered     = "webhook_delivery.redelivered"
+	AuditLogExported            = "audit_log.exported"
+)
+
+// Types of the objects targeted by audited actions
+const (
+	AuditTargetChannel         = "channel"
+	AuditTargetMessage         = "message"
+	AuditTargetReport          = "report"
+	AuditTargetSanction        = "sanction"
+	AuditTargetUser            = "user"
+	AuditTargetFilterRule      = "filter_rule"
+	AuditTargetStamp           = "stamp"
+	AuditTargetInvite          = "invite"
+	AuditTargetWebhook         = "webhook"
+	AuditTargetOutgoingWebhook = "outgoing_webhook"
+	AuditTargetWebhookDelivery = "webhook_delivery"
+)
+
+// AuditLog records a privileged operation. Entries are only ever appended.
+type AuditLog struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	ActorID    uint      `gorm:"not null;index" json:"actor_id"`
+	Action     string    `gorm:"size:50;not null;index" json:"action"`
+	TargetType string    `gorm:"size:30;index:idx_audit_target,priority:1" json:"target_type"`
+	TargetID   *uint     `gorm:"index:idx_audit_target,priority:2" json:"target_id"`
+	Before     RawJSON   `gorm:"type:jsonb" json:"before"`  // State of the target before the operation
+	After      RawJSON   `gorm:"type:jsonb" json:"after"`   // State of the target after the operation
+	Details    RawJSON   `gorm:"type:jsonb" json:"details"` // Other action-specific data
+	IP         string    `gorm:"size:45" json:"ip"`
+	CreatedAt  time.Time `gorm:"not null;default:CURRENT_TIMESTAMP;index" json:"created_at"`
+	Actor      User      `gorm:"foreignKey:ActorID" json:"-"`
+}
+
+// AuditLogResponse represents an audit log entry returned to admins
+type AuditLogResponse struct {
+	ID         uint         `json:"id"`
+	Actor      UserResponse `json:"actor"`
+	Action     string       `json:"action"`
+	TargetType string       `json:"target_type,omitempty"`
+	TargetID   *uint        `json:"target_id,omitempty"`
+	Before     RawJSON      `json:"before"`
+	After      RawJSON      `json:"after"`
+	Details    RawJSON      `json:"details"`
+	IP         string       `json:"ip"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+// ToResponse converts AuditLog to AuditLogResponse
+func (a *AuditLog) ToResponse() AuditLogResponse {
+	return AuditLogResponse{
+		ID:         a.ID,
+		Actor:      a.Actor.ToResponse(),
+		Action:     a.Action,
+		TargetType: a.TargetType,
+		TargetID:   a.TargetID,
+		Before:     a.Before,
+		After:      a.After,
+		Details:    a.Details,
+		IP:         a.IP,
+		CreatedAt:  a.CreatedAt,
+	}
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// Blob registers a drawing kept in the blob store under the digest of its content.
+// Identical drawings share a blob, which is deleted once nothing references it anymore.
+type Blob struct {
+	Key        string    `gorm:"primaryKey;size:64" json:"key"`
+	Size       int64     `gorm:"not null" json:"size"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `gorm:"not null;index" json:"last_used_at"` // Bumped whenever the drawing is stored again
+}
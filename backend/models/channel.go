@@ -6,13 +6,33 @@ import (
 	"gorm.io/gorm"
 )
 
+// Posting modes restricting what kind of message a channel accepts
+const (
+	PostingModeAll         = "all"
+	PostingModeTextOnly    = "text_only"
+	PostingModeDrawingOnly = "drawing_only"
+)
+
 // Channel represents a communication channel
 type Channel struct {
-	ID          uint           `gorm:"primaryKey;autoIncrement" json:"id"`
-	Name        string         `gorm:"size:50;not null;unique" json:"name" binding:"required"`
-	Description string         `gorm:"type:text" json:"description"`
-	CreatedAt   time.Time      `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
-	Messages    []Message      `gorm:"foreignKey:ChannelID" json:"-"`
+	ID                       uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name                     string         `gorm:"size:50;not null;unique" json:"name" binding:"required"`
+	Description              string         `gorm:"type:text" json:"description"`
+	Topic                    string         `gorm:"size:120;not null;default:''" json:"topic"`
+	SlowModeSeconds          int            `gorm:"not null;default:0" json:"slow_mode_seconds" binding:"min=0,max=86400"`
+	PostingMode              string         `gorm:"size:20;not null;default:'all'" json:"posting_mode" binding:"omitempty,oneof=all text_only drawing_only"`
+	MaxNbOfLines             int            `gorm:"not null;default:5;check:max_nb_of_lines >= 1 AND max_nb_of_lines <= 5" json:"max_nb_of_lines" binding:"omitempty,min=1,max=5"`
+	DefaultMessageTTLSeconds int            `gorm:"not null;default:0" json:"default_message_ttl_seconds" binding:"min=0,max=2592000"` // 0 keeps messages forever
+	ModeratorsOnly           bool           `gorm:"not null;default:false" json:"moderators_only"`
+	IsPrivate                bool           `gorm:"not null;default:false" json:"is_private"`
+	OwnerID                  *uint          `gorm:"index" json:"owner_id"`
+	CreatedAt                time.Time      `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt                time.Time      `json:"updated_at"`
+	DeletedAt                gorm.DeletedAt `gorm:"index" json:"-"`
+	Messages                 []Message      `gorm:"foreignKey:ChannelID" json:"-"`
+}
+
+// IsOwnedBy checks if the given user owns the channel
+func (ch *Channel) IsOwnedBy(userID uint) bool {
+	return ch.OwnerID != nil && *ch.OwnerID == userID
 }
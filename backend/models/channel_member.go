@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// ChannelMember records that a user joined a channel, granting access to private channels
+type ChannelMember struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	ChannelID uint      `gorm:"not null;uniqueIndex:idx_channel_member" json:"channel_id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_channel_member;index" json:"user_id"`
+	CreatedAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
+	Channel   Channel   `gorm:"foreignKey:ChannelID" json:"-"`
+	User      User      `gorm:"foreignKey:UserID" json:"-"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// What happens to a message matching a filter rule
+const (
+	FilterActionBlock = "block" // The message is rejected
+	FilterActionMask  = "mask"  // The matched text is replaced with asterisks
+	FilterActionFlag  = "flag"  // The message is posted and reported to the moderators
+)
+
+// FilterRule is an entry of the word filter, server-wide or limited to a channel
+type FilterRule struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	ChannelID   *uint     `gorm:"index" json:"channel_id"` // Server-wide when not set
+	Pattern     string    `gorm:"size:200;not null" json:"pattern"`
+	IsRegex     bool      `gorm:"not null;default:false" json:"is_regex"` // Otherwise a whole word or phrase, case-insensitive
+	Action      string    `gorm:"size:20;not null" json:"action"`
+	Usernames   bool      `gorm:"not null;default:false" json:"usernames"` // Also rejects matching usernames at registration (server-wide rules only)
+	CreatedByID uint      `gorm:"not null" json:"created_by_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// IdempotencyKey remembers the message, or the scheduled message, created with a client-provided key,
+// so retries do not post it twice
+type IdempotencyKey struct {
+	ID                 uint      `gorm:"primaryKey;autoIncrement"`
+	UserID             uint      `gorm:"not null;uniqueIndex:idx_idempotency_key,priority:1"`
+	Key                string    `gorm:"size:100;not null;uniqueIndex:idx_idempotency_key,priority:2"`
+	MessageID          *uint     // Set for messages posted right away
+	ScheduledMessageID *uint     // Set for scheduled messages
+	CreatedAt          time.Time `gorm:"index"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ChannelInvite represents a shareable invite link to a channel
+type ChannelInvite struct {
+	ID        uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	Code      string         `gorm:"size:32;not null;uniqueIndex" json:"code"`
+	ChannelID uint           `gorm:"not null;index" json:"channel_id"`
+	CreatorID uint           `gorm:"not null" json:"creator_id"`
+	MaxUses   int            `gorm:"not null;default:0" json:"max_uses"` // 0 means unlimited
+	Uses      int            `gorm:"not null;default:0" json:"uses"`
+	ExpiresAt *time.Time     `json:"expires_at"` // nil means the invite never expires
+	CreatedAt time.Time      `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	Channel   Channel        `gorm:"foreignKey:ChannelID" json:"-"`
+	Creator   User           `gorm:"foreignKey:CreatorID" json:"-"`
+}
+
+// IsExpired checks if the invite is past its expiry date
+func (i *ChannelInvite) IsExpired() bool {
+	return i.ExpiresAt != nil && time.Now().After(*i.ExpiresAt)
+}
+
+// IsExhausted checks if the invite has reached its maximum number of uses
+func (i *ChannelInvite) IsExhausted() bool {
+	return i.MaxUses > 0 && i.Uses >= i.MaxUses
+}
+
+// IsUsable checks if the invite can still be accepted
+func (i *ChannelInvite) IsUsable() bool {
+	return !i.IsExpired() && !i.IsExhausted()
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// RawJSON is JSON text stored in a jsonb column and embedded as is in responses
+type RawJSON string
+
+// MarshalJSON returns the stored JSON text
+func (j RawJSON) MarshalJSON() ([]byte, error) {
+	if j == "" {
+		return []byte("null"), nil
+	}
+	return []byte(j), nil
+}
+
+// Value stores empty JSON text as NULL
+func (j RawJSON) Value() (driver.Value, error) {
+	if j == "" {
+		return nil, nil
+	}
+	return string(j), nil
+}
+
+// Scan reads JSON text, NULL becomes empty
+func (j *RawJSON) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*j = ""
+	case []byte:
+		*j = RawJSON(v)
+	case string:
+		*j = RawJSON(v)
+	default:
+		return fmt.Errorf("cannot scan %T into RawJSON", value)
+	}
+	return nil
+}
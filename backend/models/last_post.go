@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// ChannelLastPost remembers when a user last posted in a channel, for slow mode.
+// It outlives the message, so deleting a message or letting it expire does not lift slow mode.
+type ChannelLastPost struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"-"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_last_post,priority:1" json:"user_id"`
+	ChannelID uint      `gorm:"not null;uniqueIndex:idx_last_post,priority:2;index" json:"channel_id"`
+	PostedAt  time.Time `gorm:"not null" json:"posted_at"`
+}
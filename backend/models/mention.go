@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// Mention records that a message mentions a user with @username
+type Mention struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	MessageID uint      `gorm:"not null;uniqueIndex:idx_mention,priority:1" json:"message_id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_mention,priority:2;index" json:"user_id"`
+	ChannelID uint      `gorm:"not null;index" json:"channel_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
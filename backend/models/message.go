@@ -9,48 +9,76 @@ import (
 
 // Message represents a message in a channel
 type Message struct {
-	ID        uint           `gorm:"primaryKey;autoIncrement" json:"id"`
-	ChannelID uint           `gorm:"not null;index" json:"channel_id" binding:"required"`
-	UserID    uint           `gorm:"not null;index" json:"user_id" binding:"required"`
-	Content   *string        `gorm:"type:text" json:"content"`
-	Image     []byte         `gorm:"type:bytea" json:"image,omitempty"`
-	NbOfLines int            `gorm:"not null;default:1;check:nb_of_lines >= 1 AND nb_of_lines <= 5" json:"nb_of_lines" binding:"required,min=1,max=5"`
-	CreatedAt time.Time      `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
-	Channel   Channel        `gorm:"foreignKey:ChannelID" json:"channel,omitempty"`
-	User      User           `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	ID          uint           `gorm:"primaryKey;autoIncrement;index:idx_channel_messages,priority:2" json:"id"`
+	ChannelID   uint           `gorm:"not null;index;index:idx_channel_messages,priority:1" json:"channel_id" binding:"required"`
+	ParentID    *uint          `gorm:"index" json:"parent_id"` // Thread the message replies to
+	UserID      uint           `gorm:"not null;index" json:"user_id" binding:"required"`
+	Content     *string        `gorm:"type:text" json:"content"`
+	Image       []byte         `gorm:"type:bytea" json:"image,omitempty"` // Drawings stored before the blob store, until migrated
+	ImageKey    *string        `gorm:"size:64;index" json:"-"`            // Drawing in the blob store
+	NbOfLines   int            `gorm:"not null;default:1;check:nb_of_lines >= 1 AND nb_of_lines <= 5" json:"nb_of_lines" binding:"required,min=1,max=5"`
+	PinnedAt    *time.Time     `gorm:"index" json:"pinned_at"`
+	PinnedByID  *uint          `json:"pinned_by_id"`
+	EditedAt    *time.Time     `json:"edited_at"`
+	ExpiresAt   *time.Time     `gorm:"index" json:"expires_at"` // Hidden once passed, then hard-deleted by the sweeper
+	CreatedAt   time.Time      `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	DeletedByID *uint          `json:"-"` // User who deleted the message, author or moderator
+	Channel     Channel        `gorm:"foreignKey:ChannelID" json:"channel,omitempty"`
+	User        User           `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	DeletedBy   *User          `gorm:"foreignKey:DeletedByID" json:"-"`
 }
 
 // MessageResponse represents the message data returned to the client
 type MessageResponse struct {
-	ID        uint         `json:"id"`
-	ChannelID uint         `json:"channel_id"`
-	UserID    uint         `json:"user_id"`
-	Content   *string      `json:"content"`
-	HasImage  bool         `json:"has_image"`
-	NbOfLines int          `json:"nb_of_lines"`
-	User      UserResponse `json:"user"`
-	CreatedAt time.Time    `json:"created_at"`
+	ID          uint              `json:"id"`
+	ParentID    *uint             `json:"parent_id,omitempty"`
+	ChannelID   uint              `json:"channel_id"`
+	UserID      uint              `json:"user_id"`
+	Content     *string           `json:"content"`
+	HasImage    bool              `json:"has_image"`
+	NbOfLines   int               `json:"nb_of_lines"`
+	PinnedAt    *time.Time        `json:"pinned_at,omitempty"`
+	EditedAt    *time.Time        `json:"edited_at,omitempty"`
+	ExpiresAt   *time.Time        `json:"expires_at,omitempty"`
+	User        UserResponse      `json:"user"`
+	Reactions   []ReactionSummary `json:"reactions"`
+	MentionIDs  []uint            `json:"mention_ids"`
+	ReplyCount  int64             `json:"reply_count"`
+	LastReplyAt *time.Time        `json:"last_reply_at,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	Nonce       string            `json:"nonce,omitempty"` // Echoes the sender's idempotency key when the message is created
 }
 
 // ToResponse converts Message to MessageResponse
 func (m *Message) ToResponse() MessageResponse {
 	return MessageResponse{
-		ID:        m.ID,
-		ChannelID: m.ChannelID,
-		UserID:    m.UserID,
-		Content:   m.Content,
-		HasImage:  len(m.Image) > 0,
-		NbOfLines: m.NbOfLines,
-		User:      m.User.ToResponse(),
-		CreatedAt: m.CreatedAt,
+		ID:         m.ID,
+		ChannelID:  m.ChannelID,
+		ParentID:   m.ParentID,
+		UserID:     m.UserID,
+		Content:    m.Content,
+		HasImage:   m.HasImage(),
+		NbOfLines:  m.NbOfLines,
+		PinnedAt:   m.PinnedAt,
+		EditedAt:   m.EditedAt,
+		ExpiresAt:  m.ExpiresAt,
+		User:       m.User.ToResponse(),
+		Reactions:  []ReactionSummary{},
+		MentionIDs: []uint{},
+		CreatedAt:  m.CreatedAt,
 	}
 }
 
+// HasImage checks whether the message has a drawing, in the blob store or not migrated yet
+func (m *Message) HasImage() bool {
+	return m.ImageKey != nil || len(m.Image) > 0
+}
+
 // BeforeCreate validates that at least one of content or image is provided
 func (m *Message) BeforeCreate(tx *gorm.DB) error {
-	if (m.Content == nil || *m.Content == "") && len(m.Image) == 0 {
+	if (m.Content == nil || *m.Content == "") && !m.HasImage() {
 		return errors.New("message must have at least one of content or image")
 	}
 	return nil
@@ -58,7 +86,7 @@ func (m *Message) BeforeCreate(tx *gorm.DB) error {
 
 // BeforeUpdate validates that at least one of content or image is provided
 func (m *Message) BeforeUpdate(tx *gorm.DB) error {
-	if (m.Content == nil || *m.Content == "") && len(m.Image) == 0 {
+	if (m.Content == nil || *m.Content == "") && !m.HasImage() {
 		return errors.New("message must have at least one of content or image")
 	}
 	return nil
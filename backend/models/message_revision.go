@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// MessageRevision stores the state of a message before one of its edits
+type MessageRevision struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	MessageID uint      `gorm:"not null;index" json:"message_id"`
+	EditorID  uint      `gorm:"not null" json:"editor_id"`
+	Content   *string   `gorm:"type:text" json:"content"`
+	Image     []byte    `gorm:"type:bytea" json:"-"` // Drawings stored before the blob store, until migrated
+	ImageKey  *string   `gorm:"size:64;index" json:"-"`
+	NbOfLines int       `gorm:"not null" json:"nb_of_lines"`
+	CreatedAt time.Time `json:"created_at"` // When the revision was replaced
+	Editor    User      `gorm:"foreignKey:EditorID" json:"-"`
+}
+
+// MessageRevisionResponse represents a revision returned to moderators
+type MessageRevisionResponse struct {
+	ID        uint         `json:"id"`
+	MessageID uint         `json:"message_id"`
+	Content   *string      `json:"content"`
+	HasImage  bool         `json:"has_image"`
+	NbOfLines int          `json:"nb_of_lines"`
+	Editor    UserResponse `json:"editor"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// ToResponse converts MessageRevision to MessageRevisionResponse
+func (r *MessageRevision) ToResponse() MessageRevisionResponse {
+	return MessageRevisionResponse{
+		ID:        r.ID,
+		MessageID: r.MessageID,
+		Content:   r.Content,
+		HasImage:  r.ImageKey != nil || len(r.Image) > 0,
+		NbOfLines: r.NbOfLines,
+		Editor:    r.Editor.ToResponse(),
+		CreatedAt: r.CreatedAt,
+	}
+}
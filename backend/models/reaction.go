@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Stamp is a small image uploaded by admins that users can react with
+type Stamp struct {
+	ID        uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name      string         `gorm:"size:32;not null;uniqueIndex" json:"name"`
+	Image     []byte         `gorm:"type:bytea;not null" json:"-"`
+	CreatorID uint           `gorm:"not null" json:"creator_id"`
+	CreatedAt time.Time      `json:"created_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// Reaction is a user's emoji or stamp reaction to a message.
+// Exactly one of Emoji and StampID is set; the other keeps its zero value so the unique index applies.
+type Reaction struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	MessageID uint      `gorm:"not null;uniqueIndex:idx_reaction,priority:1" json:"message_id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_reaction,priority:2" json:"user_id"`
+	Emoji     string    `gorm:"size:64;not null;default:'';uniqueIndex:idx_reaction,priority:3" json:"emoji,omitempty"`
+	StampID   uint      `gorm:"not null;default:0;uniqueIndex:idx_reaction,priority:4" json:"stamp_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ReactionSummary aggregates the reactions of one kind on a message
+type ReactionSummary struct {
+	Emoji   string `json:"emoji,omitempty"`
+	StampID uint   `json:"stamp_id,omitempty"`
+	Count   int64  `json:"count"`
+	Me      bool   `json:"me"` // Whether the requesting user reacted this way
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// ChannelReadState tracks the last message a user has read in a channel
+type ChannelReadState struct {
+	ID                uint      `gorm:"primaryKey;autoIncrement" json:"-"`
+	UserID            uint      `gorm:"not null;uniqueIndex:idx_read_state,priority:1" json:"user_id"`
+	ChannelID         uint      `gorm:"not null;uniqueIndex:idx_read_state,priority:2;index" json:"channel_id"`
+	LastReadMessageID uint      `gorm:"not null;default:0" json:"last_read_message_id"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
@@ -0,0 +1,115 @@
+package models
+
+import "time"
+
+// Message report statuses
+const (
+	ReportStatusOpen     = "open"
+	ReportStatusResolved = "resolved"
+)
+
+// Actions a moderator can take on a report
+const (
+	ReportActionDismiss       = "dismiss"
+	ReportActionDeleteMessage = "delete_message"
+	ReportActionMuteAuthor    = "mute_author"
+	ReportActionBanAuthor     = "ban_author"
+)
+
+// MessageReport is a user's report of a message, with a snapshot of the message taken when it was reported.
+// Reports filed by the word filter have no reporter.
+type MessageReport struct {
+	ID               uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	MessageID        uint       `gorm:"not null;uniqueIndex:idx_message_report,priority:1" json:"message_id"`
+	ReporterID       *uint      `gorm:"uniqueIndex:idx_message_report,priority:2;index" json:"reporter_id"`
+	Reason           string     `gorm:"size:500;not null" json:"reason"`
+	ChannelID        uint       `gorm:"not null" json:"channel_id"`
+	AuthorID         uint       `gorm:"not null;index" json:"author_id"`
+	Content          *string    `gorm:"type:text" json:"content"`
+	Image            []byte     `gorm:"type:bytea" json:"-"` // Drawings stored before the blob store, until migrated
+	ImageKey         *string    `gorm:"size:64;index" json:"-"`
+	NbOfLines        int        `gorm:"not null" json:"nb_of_lines"`
+	MessageCreatedAt time.Time  `json:"message_created_at"`
+	Status           string     `gorm:"size:20;not null;default:'open';index" json:"status"`
+	Action           string     `gorm:"size:20" json:"action"`
+	Note             string     `gorm:"size:500" json:"note"` // Moderator's note, shown to the reporter
+	ResolvedByID     *uint      `json:"resolved_by_id"`
+	ResolvedAt       *time.Time `json:"resolved_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+	Reporter         *User      `gorm:"foreignKey:ReporterID" json:"-"`
+	Author           User       `gorm:"foreignKey:AuthorID" json:"-"`
+}
+
+// MessageReportResponse represents a report in the moderation queue
+type MessageReportResponse struct {
+	ID           uint          `json:"id"`
+	MessageID    uint          `json:"message_id"`
+	ChannelID    uint          `json:"channel_id"`
+	Reason       string        `json:"reason"`
+	Reporter     *UserResponse `json:"reporter"` // Null for word filter reports
+	Author       UserResponse  `json:"author"`
+	Content      *string       `json:"content"`
+	HasImage     bool          `json:"has_image"`
+	NbOfLines    int           `json:"nb_of_lines"`
+	MessageAt    time.Time     `json:"message_created_at"`
+	Status       string        `json:"status"`
+	Action       string        `json:"action,omitempty"`
+	Note         string        `json:"note,omitempty"`
+	ResolvedByID *uint         `json:"resolved_by_id,omitempty"`
+	ResolvedAt   *time.Time    `json:"resolved_at,omitempty"`
+	CreatedAt    time.Time     `json:"created_at"`
+}
+
+// ToResponse converts MessageReport to MessageReportResponse
+func (r *MessageReport) ToResponse() MessageReportResponse {
+	var reporter *UserResponse
+	if r.Reporter != nil {
+		response := r.Reporter.ToResponse()
+		reporter = &response
+	}
+
+	return MessageReportResponse{
+		ID:           r.ID,
+		MessageID:    r.MessageID,
+		ChannelID:    r.ChannelID,
+		Reason:       r.Reason,
+		Reporter:     reporter,
+		Author:       r.Author.ToResponse(),
+		Content:      r.Content,
+		HasImage:     r.ImageKey != nil || len(r.Image) > 0,
+		NbOfLines:    r.NbOfLines,
+		MessageAt:    r.MessageCreatedAt,
+		Status:       r.Status,
+		Action:       r.Action,
+		Note:         r.Note,
+		ResolvedByID: r.ResolvedByID,
+		ResolvedAt:   r.ResolvedAt,
+		CreatedAt:    r.CreatedAt,
+	}
+}
+
+// ReportOutcome represents a report as seen by the user who filed it
+type ReportOutcome struct {
+	ID          uint       `json:"id"`
+	MessageID   uint       `json:"message_id"`
+	Reason      string     `json:"reason"`
+	Status      string     `json:"status"`
+	ActionTaken bool       `json:"action_taken"` // Whether the report led to a moderation action
+	Note        string     `json:"note,omitempty"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// ToOutcome converts MessageReport to ReportOutcome, without the moderation details
+func (r *MessageReport) ToOutcome() ReportOutcome {
+	return ReportOutcome{
+		ID:          r.ID,
+		MessageID:   r.MessageID,
+		Reason:      r.Reason,
+		Status:      r.Status,
+		ActionTaken: r.Status == ReportStatusResolved && r.Action != ReportActionDismiss,
+		Note:        r.Note,
+		ResolvedAt:  r.ResolvedAt,
+		CreatedAt:   r.CreatedAt,
+	}
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Sanction types
+const (
+	SanctionMute = "mute" // The user cannot post, everywhere or in one channel
+	SanctionBan  = "ban"  // The user cannot log in or use the API
+)
+
+// Sanction restricts a user until it expires or is lifted
+type Sanction struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	Type       string     `gorm:"size:20;not null" json:"type"`
+	ChannelID  *uint      `gorm:"index" json:"channel_id"` // Channel a mute applies to, global when not set
+	Reason     string     `gorm:"size:500" json:"reason"`
+	IssuedByID uint       `gorm:"not null" json:"issued_by_id"`
+	ReportID   *uint      `json:"report_id"`               // Report that led to the sanction
+	ExpiresAt  *time.Time `gorm:"index" json:"expires_at"` // Permanent when not set
+	LiftedAt   *time.Time `json:"lifted_at"`
+	LiftedByID *uint      `json:"lifted_by_id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	User       User       `gorm:"foreignKey:UserID" json:"-"`
+	IssuedBy   User       `gorm:"foreignKey:IssuedByID" json:"-"`
+}
+
+// IsActive checks if the sanction still applies
+func (s *Sanction) IsActive() bool {
+	return s.LiftedAt == nil && (s.ExpiresAt == nil || s.ExpiresAt.After(time.Now()))
+}
+
+// ActiveSanctions restricts a sanction query to the sanctions that apply now
+func ActiveSanctions(db *gorm.DB) *gorm.DB {
+	return db.Where("sanctions.lifted_at IS NULL AND (sanctions.expires_at IS NULL OR sanctions.expires_at > ?)", time.Now())
+}
+
+// SanctionResponse represents a sanction returned to moderators
+type SanctionResponse struct {
+	ID        uint         `json:"id"`
+	Type      string       `json:"type"`
+	User      UserResponse `json:"user"`
+	ChannelID *uint        `json:"channel_id"`
+	Reason    string       `json:"reason"`
+	IssuedBy  UserResponse `json:"issued_by"`
+	ReportID  *uint        `json:"report_id,omitempty"`
+	ExpiresAt *time.Time   `json:"expires_at"`
+	LiftedAt  *time.Time   `json:"lifted_at,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// ToResponse converts Sanction to SanctionResponse
+func (s *Sanction) ToResponse() SanctionResponse {
+	return SanctionResponse{
+		ID:        s.ID,
+		Type:      s.Type,
+		User:      s.User.ToResponse(),
+		ChannelID: s.ChannelID,
+		Reason:    s.Reason,
+		IssuedBy:  s.IssuedBy.ToResponse(),
+		ReportID:  s.ReportID,
+		ExpiresAt: s.ExpiresAt,
+		LiftedAt:  s.LiftedAt,
+		CreatedAt: s.CreatedAt,
+	}
+}
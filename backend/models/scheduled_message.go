@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// Scheduled message statuses
+const (
+	ScheduledStatusPending = "pending"
+	ScheduledStatusSent    = "sent"
+	ScheduledStatusFailed  = "failed"
+)
+
+// ScheduledMessage is a message waiting to be posted at SendAt
+type ScheduledMessage struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	ChannelID  uint       `gorm:"not null;index" json:"channel_id"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	ParentID   *uint      `json:"parent_id"`
+	Content    *string    `gorm:"type:text" json:"content"`
+	Image      []byte     `gorm:"type:bytea" json:"-"` // Drawings stored before the blob store, until migrated
+	ImageKey   *string    `gorm:"size:64;index" json:"-"`
+	NbOfLines  int        `gorm:"not null;default:1" json:"nb_of_lines"`
+	TTLSeconds *int       `json:"ttl_seconds"` // Requested time-to-live, counted from when the message is posted
+	SendAt     time.Time  `gorm:"not null;index:idx_scheduled_queue,priority:2" json:"send_at"`
+	Status     string     `gorm:"size:20;not null;default:'pending';index:idx_scheduled_queue,priority:1" json:"status"`
+	MessageID  *uint      `json:"message_id"` // The posted message once sent
+	LastError  string     `gorm:"type:text" json:"last_error,omitempty"`
+	Attempts   int        `gorm:"not null;default:0" json:"-"` // Attempts that hit a temporary error
+	RetryAt    *time.Time `json:"-"`                           // When the next attempt is made after a temporary error
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// ScheduledMessageResponse represents the scheduled message data returned to its author
+type ScheduledMessageResponse struct {
+	ID         uint      `json:"id"`
+	ChannelID  uint      `json:"channel_id"`
+	ParentID   *uint     `json:"parent_id,omitempty"`
+	Content    *string   `json:"content"`
+	HasImage   bool      `json:"has_image"`
+	NbOfLines  int       `json:"nb_of_lines"`
+	TTLSeconds *int      `json:"ttl_seconds,omitempty"`
+	SendAt     time.Time `json:"send_at"`
+	Status     string    `json:"status"`
+	MessageID  *uint     `json:"message_id,omitempty"`
+	LastError  string    `json:"last_error,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ToResponse converts ScheduledMessage to ScheduledMessageResponse
+func (s *ScheduledMessage) ToResponse() ScheduledMessageResponse {
+	return ScheduledMessageResponse{
+		ID:         s.ID,
+		ChannelID:  s.ChannelID,
+		ParentID:   s.ParentID,
+		Content:    s.Content,
+		HasImage:   s.ImageKey != nil || len(s.Image) > 0,
+		NbOfLines:  s.NbOfLines,
+		TTLSeconds: s.TTLSeconds,
+		SendAt:     s.SendAt,
+		Status:     s.Status,
+		MessageID:  s.MessageID,
+		LastError:  s.LastError,
+		CreatedAt:  s.CreatedAt,
+	}
+}
@@ -8,14 +8,15 @@ import (
 
 // User represents a user in the system
 type User struct {
-	ID        uint           `gorm:"primaryKey;autoIncrement" json:"id"`
-	Name      string         `gorm:"size:50;not null;unique" json:"name" binding:"required"`
-	Password  string         `gorm:"size:100;not null" json:"-"`
-	Role      string         `gorm:"size:20;not null;default:'user'" json:"role"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
-	Messages  []Message      `gorm:"foreignKey:UserID" json:"-"`
+	ID           uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name         string         `gorm:"size:50;not null;unique" json:"name" binding:"required"`
+	Password     string         `gorm:"size:100;not null" json:"-"`
+	Role         string         `gorm:"size:20;not null;default:'user'" json:"role"`
+	ShadowBanned bool           `gorm:"not null;default:false" json:"-"` // Messages only shown to the user and the moderators
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+	Messages     []Message      `gorm:"foreignKey:UserID" json:"-"`
 }
 
 // IsAdmin checks if the user has admin role
@@ -23,6 +24,16 @@ func (u *User) IsAdmin() bool {
 	return u.Role == "admin"
 }
 
+// IsModerator checks if the user has moderator privileges (admins included)
+func (u *User) IsModerator() bool {
+	return u.Role == "moderator" || u.IsAdmin()
+}
+
+// IsWebhook checks if the user is the identity of an incoming webhook
+func (u *User) IsWebhook() bool {
+	return u.Role == "webhook"
+}
+
 // UserResponse represents the user data returned to the client (without password)
 type UserResponse struct {
 	ID        uint      `json:"id"`
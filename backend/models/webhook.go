@@ -0,0 +1,135 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// IncomingWebhook lets external scripts post into a channel through a secret URL
+type IncomingWebhook struct {
+	ID        uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	ChannelID uint           `gorm:"not null;index" json:"channel_id"`
+	UserID    uint           `gorm:"not null" json:"user_id"` // Identity the messages are posted under
+	CreatorID uint           `gorm:"not null" json:"creator_id"`
+	TokenHash string         `gorm:"size:64;not null" json:"-"`
+	CreatedAt time.Time      `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	Channel   Channel        `gorm:"foreignKey:ChannelID" json:"-"`
+	User      User           `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// IncomingWebhookResponse represents the webhook data returned to the client
+type IncomingWebhookResponse struct {
+	ID        uint         `json:"id"`
+	ChannelID uint         `json:"channel_id"`
+	CreatorID uint         `json:"creator_id"`
+	User      UserResponse `json:"user"`
+	URL       string       `json:"url,omitempty"` // Only returned when the webhook is created
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// ToResponse converts IncomingWebhook to IncomingWebhookResponse
+func (w *IncomingWebhook) ToResponse() IncomingWebhookResponse {
+	return IncomingWebhookResponse{
+		ID:        w.ID,
+		ChannelID: w.ChannelID,
+		CreatorID: w.CreatorID,
+		User:      w.User.ToResponse(),
+		CreatedAt: w.CreatedAt,
+	}
+}
+
+// Delivery statuses of outgoing webhook events
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusSucceeded = "succeeded"
+	DeliveryStatusFailed    = "failed"
+	DeliveryStatusCancelled = "cancelled"
+)
+
+// OutgoingWebhook delivers signed notifications about server events to an external URL
+type OutgoingWebhook struct {
+	ID        uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	URL       string         `gorm:"size:2048;not null" json:"url"`
+	Secret    string         `gorm:"size:100;not null" json:"-"`
+	Events    string         `gorm:"type:text;not null" json:"-"` // Comma separated event types
+	Active    bool           `gorm:"not null" json:"active"`
+	CreatorID uint           `gorm:"not null" json:"creator_id"`
+	CreatedAt time.Time      `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// EventList returns the event types the webhook is subscribed to
+func (w *OutgoingWebhook) EventList() []string {
+	if w.Events == "" {
+		return []string{}
+	}
+	return strings.Split(w.Events, ",")
+}
+
+// Subscribes checks if the webhook wants to receive an event type
+func (w *OutgoingWebhook) Subscribes(eventType string) bool {
+	for _, event := range w.EventList() {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// OutgoingWebhookResponse represents the outgoing webhook data returned to the client
+type OutgoingWebhookResponse struct {
+	ID        uint      `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	Secret    string    `json:"secret,omitempty"` // Only returned when the webhook is created
+	CreatorID uint      `json:"creator_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ToResponse converts OutgoingWebhook to OutgoingWebhookResponse
+func (w *OutgoingWebhook) ToResponse() OutgoingWebhookResponse {
+	return OutgoingWebhookResponse{
+		ID:        w.ID,
+		URL:       w.URL,
+		Events:    w.EventList(),
+		Active:    w.Active,
+		CreatorID: w.CreatorID,
+		CreatedAt: w.CreatedAt,
+		UpdatedAt: w.UpdatedAt,
+	}
+}
+
+// WebhookDelivery is an event queued for delivery to an outgoing webhook
+type WebhookDelivery struct {
+	ID             uint                     `gorm:"primaryKey;autoIncrement" json:"id"`
+	WebhookID      uint                     `gorm:"not null;index" json:"webhook_id"`
+	EventType      string                   `gorm:"size:50;not null" json:"event_type"`
+	Payload        RawJSON                  `gorm:"type:jsonb;not null" json:"payload"`
+	Status         string                   `gorm:"size:20;not null;default:'pending';index:idx_delivery_queue,priority:1" json:"status"`
+	Attempts       int                      `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt  time.Time                `gorm:"not null;index:idx_delivery_queue,priority:2" json:"next_attempt_at"`
+	LastStatusCode int                      `gorm:"not null;default:0" json:"last_status_code"`
+	LastError      string                   `gorm:"type:text" json:"last_error"`
+	DeliveredAt    *time.Time               `json:"delivered_at"`
+	CreatedAt      time.Time                `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt      time.Time                `json:"updated_at"`
+	DeliveryLog    []WebhookDeliveryAttempt `gorm:"foreignKey:DeliveryID" json:"attempt_log,omitempty"`
+}
+
+// WebhookDeliveryAttempt records a single HTTP request made for a delivery
+type WebhookDeliveryAttempt struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	DeliveryID   uint      `gorm:"not null;index" json:"delivery_id"`
+	StatusCode   int       `gorm:"not null;default:0" json:"status_code"` // 0 when no response was received
+	Error        string    `gorm:"type:text" json:"error"`
+	ResponseBody string    `gorm:"type:text" json:"response_body"`
+	DurationMs   int64     `gorm:"not null" json:"duration_ms"`
+	CreatedAt    time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
+}
@@ -25,6 +25,12 @@ func SetupRoutes(router *gin.Engine, hub *ws.Hub) {
 			auth.POST("/login", handlers.Login)
 		}
 
+		// Public invite preview
+		v1.GET("/invites/:code", handlers.GetInvitePreview)
+
+		// Incoming webhooks (authenticated by the token in the URL)
+		v1.POST("/hooks/:id/:token", handlers.ExecuteWebhook)
+
 		// WebSocket endpoint (token passed in URL query parameter)
 		v1.GET("/ws", handlers.WSHandler(hub))
 
@@ -34,6 +40,8 @@ func SetupRoutes(router *gin.Engine, hub *ws.Hub) {
 		{
 			// User routes
 			protected.GET("/me", handlers.GetCurrentUser)
+			protected.GET("/me/mentions", handlers.GetMentions)
+			protected.GET("/me/reports", handlers.GetMyReports)
 
 			// Channel routes
 			channels := protected.Group("/channels")
@@ -42,11 +50,104 @@ func SetupRoutes(router *gin.Engine, hub *ws.Hub) {
 				channels.POST("", middleware.AdminMiddleware(), handlers.CreateChannel)
 				channels.PUT("/:id", middleware.AdminMiddleware(), handlers.UpdateChannel)
 				channels.DELETE("/:id", middleware.AdminMiddleware(), handlers.DeleteChannel)
+				channels.GET("/:id/stats", middleware.AdminMiddleware(), handlers.GetChannelStats)
+
+				// Moderator routes
+				channels.PUT("/:id/topic", middleware.ModeratorMiddleware(), handlers.UpdateChannelTopic)
+				channels.GET("/:id/deleted-messages", middleware.ModeratorMiddleware(), handlers.GetDeletedMessages)
 
 				// All authenticated users can read
 				channels.GET("", handlers.GetChannels)
 				channels.GET("/:id", handlers.GetChannel)
 				channels.GET("/:id/messages", handlers.GetChannelMessages)
+				channels.GET("/:id/pins", handlers.GetChannelPins)
+				channels.POST("/:id/read", handlers.MarkChannelRead)
+
+				// Admins and channel owners manage invites
+				channels.POST("/:id/invites", handlers.CreateInvite)
+				channels.GET("/:id/invites", handlers.GetChannelInvites)
+
+				// Admins and channel owners manage incoming webhooks
+				channels.POST("/:id/webhooks", handlers.CreateWebhook)
+				channels.GET("/:id/webhooks", handlers.GetChannelWebhooks)
+			}
+
+			// Scheduled message routes (the author's own)
+			scheduled := protected.Group("/scheduled-messages")
+			{
+				scheduled.GET("", handlers.GetScheduledMessages)
+				scheduled.PATCH("/:id", handlers.UpdateScheduledMessage)
+				scheduled.DELETE("/:id", handlers.CancelScheduledMessage)
+			}
+
+			// Search routes
+			protected.GET("/search/messages", handlers.SearchMessages)
+
+			// Stamps usable as reactions
+			protected.GET("/stamps", handlers.GetStamps)
+			protected.GET("/stamps/:id/image", handlers.GetStampImage)
+
+			// Incoming webhook routes
+			protected.DELETE("/webhooks/:id", handlers.DeleteWebhook)
+
+			// Invite routes
+			invites := protected.Group("/invites")
+			{
+				invites.POST("/:code/accept", handlers.AcceptInvite)
+				invites.DELETE("/:code", handlers.RevokeInvite)
+			}
+
+			// Moderation queue
+			moderation := protected.Group("/moderation")
+			moderation.Use(middleware.ModeratorMiddleware())
+			{
+				moderation.GET("/reports", handlers.GetReports)
+				moderation.GET("/reports/:id", handlers.GetReport)
+				moderation.GET("/reports/:id/image", handlers.GetReportImage)
+				moderation.POST("/reports/:id/resolve", handlers.ResolveReport)
+
+				// Mutes, timeouts and bans
+				moderation.POST("/sanctions", handlers.CreateSanction)
+				moderation.GET("/sanctions", handlers.GetSanctions)
+				moderation.DELETE("/sanctions/:id", handlers.LiftSanction)
+
+				// Shadow bans
+				moderation.GET("/shadow-bans", handlers.GetShadowBannedUsers)
+				moderation.PUT("/users/:id/shadow-ban", handlers.ShadowBanUser)
+				moderation.DELETE("/users/:id/shadow-ban", handlers.LiftShadowBan)
+			}
+
+			// Admin routes
+			admin := protected.Group("/admin")
+			admin.Use(middleware.AdminMiddleware())
+			{
+				admin.GET("/stats", handlers.GetServerStats)
+
+				// Audit log
+				admin.GET("/audit", handlers.GetAuditLog)
+				admin.GET("/audit/export", handlers.ExportAuditLog)
+
+				// Bulk message deletion
+				admin.POST("/messages/purge", handlers.PurgeMessages)
+
+				// Word filter
+				admin.GET("/filters", handlers.GetFilterRules)
+				admin.POST("/filters", handlers.CreateFilterRule)
+				admin.DELETE("/filters/:id", handlers.DeleteFilterRule)
+
+				// Reaction stamps
+				admin.POST("/stamps", handlers.CreateStamp)
+				admin.DELETE("/stamps/:id", handlers.DeleteStamp)
+
+				// Outgoing webhooks
+				admin.POST("/webhooks", handlers.CreateOutgoingWebhook)
+				admin.GET("/webhooks", handlers.GetOutgoingWebhooks)
+				admin.PUT("/webhooks/:id", handlers.UpdateOutgoingWebhook)
+				admin.DELETE("/webhooks/:id", handlers.DeleteOutgoingWebhook)
+				admin.POST("/webhooks/:id/ping", handlers.PingOutgoingWebhook)
+				admin.GET("/webhooks/:id/deliveries", handlers.GetWebhookDeliveries)
+				admin.GET("/webhook-deliveries/:id", handlers.GetWebhookDelivery)
+				admin.POST("/webhook-deliveries/:id/redeliver", handlers.RedeliverWebhookDelivery)
 			}
 
 			// Message routes
@@ -56,7 +157,23 @@ func SetupRoutes(router *gin.Engine, hub *ws.Hub) {
 				messages.GET("", handlers.GetMessages)
 				messages.GET("/:id", handlers.GetMessage)
 				messages.GET("/:id/image", handlers.GetMessageImage)
+				messages.GET("/:id/replies", handlers.GetMessageReplies)
+				messages.GET("/:id/readers", handlers.GetMessageReaders)
+				messages.PATCH("/:id", handlers.EditMessage)
 				messages.DELETE("/:id", handlers.DeleteMessage)
+				messages.POST("/:id/report", handlers.ReportMessage)
+				messages.GET("/:id/reactions/:reaction", handlers.GetReactionUsers)
+				messages.PUT("/:id/reactions/:reaction", handlers.AddReaction)
+				messages.DELETE("/:id/reactions/:reaction", handlers.RemoveReaction)
+
+				// Moderator routes
+				messages.POST("/:id/pin", middleware.ModeratorMiddleware(), handlers.PinMessage)
+				messages.DELETE("/:id/pin", middleware.ModeratorMiddleware(), handlers.UnpinMessage)
+				messages.GET("/:id/revisions", middleware.ModeratorMiddleware(), handlers.GetMessageRevisions)
+				messages.GET("/:id/revisions/:revisionId/image", middleware.ModeratorMiddleware(), handlers.GetMessageRevisionImage)
+				messages.GET("/:id/deleted-image", middleware.ModeratorMiddleware(), handlers.GetDeletedMessageImage)
+				messages.POST("/:id/restore", middleware.ModeratorMiddleware(), handlers.RestoreMessage)
+				messages.DELETE("/:id/purge", middleware.ModeratorMiddleware(), handlers.PurgeDeletedMessage)
 			}
 		}
 	}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FilesystemStore keeps blobs as files in a directory, fanned out by the first bytes of their key
+type FilesystemStore struct {
+	root string
+}
+
+// NewFilesystemStore creates a store in dir, creating the directory if needed
+func NewFilesystemStore(dir string) (*FilesystemStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("cannot create blob directory: %w", err)
+	}
+	return &FilesystemStore{root: dir}, nil
+}
+
+// path returns the file of a blob, e.g. ab/cd/abcd...
+func (s *FilesystemStore) path(key string) (string, error) {
+	if !ValidKey(key) {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(s.root, key[0:2], key[2:4], key), nil
+}
+
+// Put writes the blob to a temporary file first, so that readers never see a partial blob
+func (s *FilesystemStore) Put(ctx context.Context, key string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Get opens the file of a blob
+func (s *FilesystemStore) Get(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, 0, ErrNotFound
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+	return file, info.Size(), nil
+}
+
+// Exists checks for the file of a blob
+func (s *FilesystemStore) Exists(ctx context.Context, key string) (bool, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Delete removes the file of a blob
+func (s *FilesystemStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
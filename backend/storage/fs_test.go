@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFilesystemStore(t *testing.T) {
+	ctx := context.Background()
+	root := filepath.Join(t.TempDir(), "blobs")
+	store, err := NewFilesystemStore(root)
+	if err != nil {
+		t.Fatalf("NewFilesystemStore: %v", err)
+	}
+
+	data := []byte("hello")
+	key := Key(data)
+
+	if exists, err := store.Exists(ctx, key); err != nil || exists {
+		t.Fatalf("Exists before Put = %v, %v, want false", exists, err)
+	}
+	if _, _, err := store.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get before Put: %v, want ErrNotFound", err)
+	}
+
+	if err := store.Put(ctx, key, data); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	// Storing the same content again is harmless
+	if err := store.Put(ctx, key, data); err != nil {
+		t.Fatalf("second Put: %v", err)
+	}
+
+	// Blobs are fanned out by the first bytes of their key, without leftover temporary files
+	entries, err := os.ReadDir(filepath.Join(root, key[0:2], key[2:4]))
+	if err != nil || len(entries) != 1 || entries[0].Name() != key {
+		t.Fatalf("blob directory = %v (%v), want only %s", entries, err, key)
+	}
+
+	if exists, err := store.Exists(ctx, key); err != nil || !exists {
+		t.Fatalf("Exists after Put = %v, %v, want true", exists, err)
+	}
+
+	reader, size, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	got, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil || string(got) != string(data) || size != int64(len(data)) {
+		t.Fatalf("Get = %q (%d bytes, %v), want %q", got, size, err, data)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if exists, err := store.Exists(ctx, key); err != nil || exists {
+		t.Fatalf("Exists after Delete = %v, %v, want false", exists, err)
+	}
+	// Deleting a missing blob is not an error
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("second Delete: %v", err)
+	}
+}
+
+func TestFilesystemStoreRejectsInvalidKeys(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFilesystemStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFilesystemStore: %v", err)
+	}
+
+	for _, key := range []string{"", "../../etc/passwd", Key(nil)[:63], Key(nil) + "/x"} {
+		if err := store.Put(ctx, key, []byte("x")); err == nil {
+			t.Errorf("Put(%q) succeeded, want an error", key)
+		}
+		if _, _, err := store.Get(ctx, key); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%q) = %v, want an invalid key error", key, err)
+		}
+	}
+}
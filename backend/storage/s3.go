@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"pictorial-backend/config"
+)
+
+const (
+	// Time allowed for a request to the object store
+	s3RequestTimeout = 30 * time.Second
+
+	// Maximum number of error bytes read from a failed response
+	maxS3ErrorBody = 1024
+
+	// Timestamp formats of Signature Version 4
+	amzDateFormat  = "20060102T150405Z"
+	amzScopeFormat = "20060102"
+)
+
+// Digest of an empty payload, signed for requests without a body
+var emptyPayloadHash = hex.EncodeToString(sha256.New().Sum(nil))
+
+// S3Store keeps blobs as objects of an S3-compatible bucket, signing requests with Signature Version 4
+type S3Store struct {
+	endpoint *url.URL
+	cfg      config.S3Config
+	client   *http.Client
+	now      func() time.Time // Signing clock, replaced by tests
+}
+
+// NewS3Store creates a store for the configured bucket
+func NewS3Store(cfg config.S3Config) (*S3Store, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" || cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, errors.New("S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY are required")
+	}
+
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("invalid S3_ENDPOINT %q", cfg.Endpoint)
+	}
+
+	return &S3Store{
+		endpoint: endpoint,
+		cfg:      cfg,
+		client:   &http.Client{Timeout: s3RequestTimeout},
+		now:      time.Now,
+	}, nil
+}
+
+// objectURL returns the URL of the object of a blob
+func (s *S3Store) objectURL(key string) (*url.URL, error) {
+	if !ValidKey(key) {
+		return nil, fmt.Errorf("invalid blob key %q", key)
+	}
+
+	object := *s.endpoint
+	base := strings.TrimSuffix(object.Path, "/")
+	if s.cfg.PathStyle {
+		base += "/" + s.cfg.Bucket
+	} else {
+		object.Host = s.cfg.Bucket + "." + object.Host
+	}
+	object.Path = base + "/" + s.cfg.Prefix + key
+	object.RawPath = ""
+	return &object, nil
+}
+
+// do sends a signed request for the object of a blob
+func (s *S3Store) do(ctx context.Context, method string, key string, body []byte) (*http.Response, error) {
+	object, err := s.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var reader io.Reader
+	payloadHash := emptyPayloadHash
+	if body != nil {
+		reader = bytes.NewReader(body)
+		sum := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, object.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	s.sign(req, payloadHash, s.now().UTC())
+
+	return s.client.Do(req)
+}
+
+// sign adds the Signature Version 4 authorization of a request
+func (s *S3Store) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format(amzDateFormat)
+	scope := strings.Join([]string{now.Format(amzScopeFormat), s.cfg.Region, "s3", "aws4_request"}, "/")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// Keys are hex digests, their paths need no escaping beyond what EscapedPath does
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		"", // No query string
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.cfg.SecretAccessKey), now.Format(amzScopeFormat))
+	signingKey = hmacSHA256(signingKey, s.cfg.Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 computes the HMAC-SHA256 of data with key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// responseError describes a failed request, with the start of the error document
+func responseError(method string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxS3ErrorBody))
+	return fmt.Errorf("S3 %s answered %s: %s", method, resp.Status, strings.TrimSpace(string(body)))
+}
+
+// Put uploads the object of a blob
+func (s *S3Store) Put(ctx context.Context, key string, data []byte) error {
+	resp, err := s.do(ctx, http.MethodPut, key, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError(http.MethodPut, resp)
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// Get downloads the object of a blob, the caller reads the body as it arrives
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, resp.ContentLength, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, 0, ErrNotFound
+	default:
+		defer resp.Body.Close()
+		return nil, 0, responseError(http.MethodGet, resp)
+	}
+}
+
+// Exists checks for the object of a blob
+func (s *S3Store) Exists(ctx context.Context, key string) (bool, error) {
+	resp, err := s.do(ctx, http.MethodHead, key, nil)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("S3 %s answered %s", http.MethodHead, resp.Status)
+	}
+}
+
+// Delete removes the object of a blob, S3 answers 204 whether or not it existed
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return responseError(http.MethodDelete, resp)
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"pictorial-backend/config"
+)
+
+// Signatures for the fixed time below, computed independently of the store
+var expectedSignatures = map[string]string{
+	http.MethodPut:    "f7ea3f671431899cdf0e3ac44bf43c539411506a695c6530fc6e5b2c080a1576",
+	http.MethodGet:    "b64770432a998b967557bf87820272482f293a9c65af417965c6ea5362edc9e0",
+	http.MethodHead:   "792e9ffe727ee2474dcf9fa3ef7c4abbdfcf3d1c290a3f503d188f7a9b781f14",
+	http.MethodDelete: "6c9b7b66f9db83d4126ed64bd1f4c5860462ffd9e62d5ee0bb7c0b55930628a2",
+}
+
+// newTestS3Store returns a path-style store signing for http://minio:9000 at a fixed time,
+// whose requests are answered by handler
+func newTestS3Store(t *testing.T, handler http.HandlerFunc) *S3Store {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	store, err := NewS3Store(config.S3Config{
+		Endpoint:        "http://minio:9000",
+		Region:          "us-east-1",
+		Bucket:          "pictorial",
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "secret",
+		PathStyle:       true,
+		Prefix:          "drawings/",
+	})
+	if err != nil {
+		t.Fatalf("NewS3Store: %v", err)
+	}
+	store.now = func() time.Time { return time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC) }
+
+	// Keep the signed host name, but connect to the local server
+	store.client.Transport = &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+		},
+	}
+	return store
+}
+
+// checkSignedRequest checks the method, path-style URL and authorization of a request
+func checkSignedRequest(t *testing.T, r *http.Request, method string, key string) {
+	t.Helper()
+
+	if r.Method != method {
+		t.Errorf("method = %s, want %s", r.Method, method)
+	}
+	if r.Host != "minio:9000" {
+		t.Errorf("host = %s, want minio:9000", r.Host)
+	}
+	if want := "/pictorial/drawings/" + key; r.URL.Path != want {
+		t.Errorf("path = %s, want %s", r.URL.Path, want)
+	}
+	if got := r.Header.Get("X-Amz-Date"); got != "20261019T120000Z" {
+		t.Errorf("X-Amz-Date = %s", got)
+	}
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20261019/us-east-1/s3/aws4_request, " +
+		"SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=" + expectedSignatures[method]
+	if got := r.Header.Get("Authorization"); got != want {
+		t.Errorf("%s Authorization = %s, want %s", method, got, want)
+	}
+}
+
+func TestS3StorePut(t *testing.T) {
+	data := []byte("hello")
+	key := Key(data)
+
+	store := newTestS3Store(t, func(w http.ResponseWriter, r *http.Request) {
+		checkSignedRequest(t, r, http.MethodPut, key)
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != string(data) {
+			t.Errorf("body = %q, want %q", body, data)
+		}
+		if got := r.Header.Get("X-Amz-Content-Sha256"); got != key {
+			t.Errorf("X-Amz-Content-Sha256 = %s, want %s", got, key)
+		}
+	})
+
+	if err := store.Put(context.Background(), key, data); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+}
+
+func TestS3StoreGet(t *testing.T) {
+	key := Key([]byte("hello"))
+
+	store := newTestS3Store(t, func(w http.ResponseWriter, r *http.Request) {
+		checkSignedRequest(t, r, http.MethodGet, key)
+		if got := r.Header.Get("X-Amz-Content-Sha256"); got != emptyPayloadHash {
+			t.Errorf("X-Amz-Content-Sha256 = %s, want the empty payload digest", got)
+		}
+		io.WriteString(w, "hello")
+	})
+
+	reader, size, err := store.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer reader.Close()
+	got, _ := io.ReadAll(reader)
+	if string(got) != "hello" || size != 5 {
+		t.Errorf("Get = %q (%d bytes), want hello", got, size)
+	}
+}
+
+func TestS3StoreGetMissing(t *testing.T) {
+	store := newTestS3Store(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	if _, _, err := store.Get(context.Background(), Key([]byte("hello"))); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get = %v, want ErrNotFound", err)
+	}
+}
+
+func TestS3StoreExists(t *testing.T) {
+	key := Key([]byte("hello"))
+	status := http.StatusOK
+
+	store := newTestS3Store(t, func(w http.ResponseWriter, r *http.Request) {
+		checkSignedRequest(t, r, http.MethodHead, key)
+		w.WriteHeader(status)
+	})
+
+	if exists, err := store.Exists(context.Background(), key); err != nil || !exists {
+		t.Errorf("Exists = %v, %v, want true", exists, err)
+	}
+	status = http.StatusNotFound
+	if exists, err := store.Exists(context.Background(), key); err != nil || exists {
+		t.Errorf("Exists = %v, %v, want false", exists, err)
+	}
+	status = http.StatusForbidden
+	if _, err := store.Exists(context.Background(), key); err == nil {
+		t.Error("Exists succeeded on 403 Forbidden, want an error")
+	}
+}
+
+func TestS3StoreDelete(t *testing.T) {
+	key := Key([]byte("hello"))
+
+	store := newTestS3Store(t, func(w http.ResponseWriter, r *http.Request) {
+		checkSignedRequest(t, r, http.MethodDelete, key)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	if err := store.Delete(context.Background(), key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+}
+
+func TestS3StoreReportsErrors(t *testing.T) {
+	store := newTestS3Store(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, "<Error><Code>AccessDenied</Code></Error>")
+	})
+
+	err := store.Put(context.Background(), Key([]byte("hello")), []byte("hello"))
+	if err == nil || err.Error() != "S3 PUT answered 403 Forbidden: <Error><Code>AccessDenied</Code></Error>" {
+		t.Errorf("Put = %v, want the error document", err)
+	}
+}
+
+func TestS3StoreVirtualHostedURL(t *testing.T) {
+	store, err := NewS3Store(config.S3Config{
+		Endpoint:        "https://s3.eu-west-3.amazonaws.com/",
+		Region:          "eu-west-3",
+		Bucket:          "pictorial",
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "secret",
+	})
+	if err != nil {
+		t.Fatalf("NewS3Store: %v", err)
+	}
+
+	key := Key([]byte("hello"))
+	object, err := store.objectURL(key)
+	if err != nil {
+		t.Fatalf("objectURL: %v", err)
+	}
+	if want := "https://pictorial.s3.eu-west-3.amazonaws.com/" + key; object.String() != want {
+		t.Errorf("objectURL = %s, want %s", object, want)
+	}
+	if _, err := store.objectURL("../secrets"); err == nil {
+		t.Error("objectURL accepted an invalid key")
+	}
+}
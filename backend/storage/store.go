@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+
+	"pictorial-backend/config"
+)
+
+// ErrNotFound is returned when a blob is not in the store
+var ErrNotFound = errors.New("blob not found")
+
+// Keys are the hex encoded SHA-256 digest of the content
+var keyPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// Store keeps immutable blobs addressed by the digest of their content
+type Store interface {
+	// Put stores data under key, replacing identical content is harmless
+	Put(ctx context.Context, key string, data []byte) error
+	// Get opens a blob for reading along with its size, ErrNotFound if it is missing
+	Get(ctx context.Context, key string) (io.ReadCloser, int64, error)
+	// Exists checks whether a blob is stored
+	Exists(ctx context.Context, key string) (bool, error)
+	// Delete removes a blob, deleting a missing blob is not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// Key returns the content address of data
+func Key(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ValidKey checks that a key is a content address, so that it is safe in paths and URLs
+func ValidKey(key string) bool {
+	return keyPattern.MatchString(key)
+}
+
+// New creates the store selected by the BLOB_STORE environment variable
+func New() (Store, error) {
+	switch backend := config.GetBlobStore(); backend {
+	case config.BlobStoreFilesystem:
+		return NewFilesystemStore(config.GetBlobDir())
+	case config.BlobStoreS3:
+		return NewS3Store(config.GetS3Config())
+	default:
+		return nil, fmt.Errorf("unknown BLOB_STORE %q, expected %s or %s", backend, config.BlobStoreFilesystem, config.BlobStoreS3)
+	}
+}
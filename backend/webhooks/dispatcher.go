@@ -0,0 +1,372 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"pictorial-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Event types that outgoing webhooks can subscribe to
+const (
+	EventMessageCreated  = "message.created"
+	EventMessageUpdated  = "message.updated"
+	EventMessageDeleted  = "message.deleted"
+	EventMessageRestored = "message.restored"
+	EventMessagesPurged  = "messages.purged"
+	EventChannelCreated  = "channel.created"
+	EventChannelUpdated  = "channel.updated"
+	EventChannelDeleted  = "channel.deleted"
+	EventUserRegistered  = "user.registered"
+
+	// EventPing is sent on demand to test a webhook, regardless of its subscriptions
+	EventPing = "ping"
+)
+
+// EventTypes lists the event types webhooks can subscribe to
+var EventTypes = []string{
+	EventMessageCreated,
+	EventMessageUpdated,
+	EventMessageDeleted,
+	EventMessageRestored,
+	EventMessagesPurged,
+	EventChannelCreated,
+	EventChannelUpdated,
+	EventChannelDeleted,
+	EventUserRegistered,
+}
+
+const (
+	// Time allowed for the receiver to answer
+	requestTimeout = 10 * time.Second
+
+	// How often the queue is checked when nothing wakes the dispatcher
+	pollInterval = 5 * time.Second
+
+	// Number of deliveries claimed at once
+	batchSize = 20
+
+	// How long a claimed delivery is hidden from other workers. The batch is sent sequentially,
+	// so the lease outlasts a batch of receivers all timing out.
+	claimLease = batchSize*requestTimeout + time.Minute
+
+	// A delivery is marked failed after this many attempts
+	maxAttempts = 8
+
+	// Retry delays double from baseBackoff up to maxBackoff
+	baseBackoff = 10 * time.Second
+	maxBackoff  = time.Hour
+
+	// Maximum number of response bytes recorded for an attempt
+	maxRecordedResponse = 1024
+)
+
+// Payload is the JSON body POSTed to webhook receivers
+type Payload struct {
+	Event     string      `json:"event"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// Dispatcher queues events in the database and delivers them to outgoing webhooks
+type Dispatcher struct {
+	db     *gorm.DB
+	client *http.Client
+
+	// Wakes the delivery loop when new work is queued
+	wake chan struct{}
+}
+
+// NewDispatcher creates a new Dispatcher instance
+func NewDispatcher(db *gorm.DB) *Dispatcher {
+	return &Dispatcher{
+		db:     db,
+		client: &http.Client{Timeout: requestTimeout},
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Run starts the dispatcher's delivery loop. Pending deliveries survive restarts
+// since the queue lives in the database.
+func (d *Dispatcher) Run() {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			processed, err := d.processBatch()
+			if err != nil {
+				log.Printf("Webhook dispatcher error: %v", err)
+				break
+			}
+			if processed < batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-d.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Enqueue queues an event for every active webhook subscribed to it
+func (d *Dispatcher) Enqueue(eventType string, data interface{}) error {
+	var hooks []models.OutgoingWebhook
+	if err := d.db.Where("active = ?", true).Find(&hooks).Error; err != nil {
+		return err
+	}
+
+	var subscribed []models.OutgoingWebhook
+	for _, hook := range hooks {
+		if hook.Subscribes(eventType) {
+			subscribed = append(subscribed, hook)
+		}
+	}
+	if len(subscribed) == 0 {
+		return nil
+	}
+
+	_, err := d.enqueue(subscribed, eventType, data)
+	return err
+}
+
+// Ping queues a ping event for a webhook, whatever its subscriptions
+func (d *Dispatcher) Ping(hook *models.OutgoingWebhook) (*models.WebhookDelivery, error) {
+	deliveries, err := d.enqueue([]models.OutgoingWebhook{*hook}, EventPing, map[string]interface{}{"webhook_id": hook.ID})
+	if err != nil {
+		return nil, err
+	}
+	return &deliveries[0], nil
+}
+
+// Redeliver puts a delivery back in the queue for an immediate attempt
+func (d *Dispatcher) Redeliver(delivery *models.WebhookDelivery) error {
+	now := time.Now()
+	if err := d.db.Model(delivery).Updates(map[string]interface{}{
+		"status":          models.DeliveryStatusPending,
+		"attempts":        0,
+		"next_attempt_at": now,
+		"delivered_at":    nil,
+	}).Error; err != nil {
+		return err
+	}
+
+	d.notify()
+	return nil
+}
+
+// enqueue stores one delivery per webhook and wakes the delivery loop
+func (d *Dispatcher) enqueue(hooks []models.OutgoingWebhook, eventType string, data interface{}) ([]models.WebhookDelivery, error) {
+	body, err := json.Marshal(Payload{
+		Event:     eventType,
+		Timestamp: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	deliveries := make([]models.WebhookDelivery, 0, len(hooks))
+	for _, hook := range hooks {
+		deliveries = append(deliveries, models.WebhookDelivery{
+			WebhookID:     hook.ID,
+			EventType:     eventType,
+			Payload:       models.RawJSON(body),
+			Status:        models.DeliveryStatusPending,
+			NextAttemptAt: now,
+		})
+	}
+
+	if err := d.db.Create(&deliveries).Error; err != nil {
+		return nil, err
+	}
+
+	d.notify()
+	return deliveries, nil
+}
+
+// notify wakes the delivery loop without blocking
+func (d *Dispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// processBatch claims due deliveries and attempts them, returning how many were claimed
+func (d *Dispatcher) processBatch() (int, error) {
+	var deliveries []models.WebhookDelivery
+
+	// Claim deliveries by pushing their next attempt into the future, so other
+	// instances skip them and a crash mid-delivery only delays them by the lease
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.DeliveryStatusPending, time.Now()).
+			Order("next_attempt_at").
+			Limit(batchSize).
+			Find(&deliveries).Error; err != nil {
+			return err
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, len(deliveries))
+		for i, delivery := range deliveries {
+			ids[i] = delivery.ID
+		}
+		return tx.Model(&models.WebhookDelivery{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", time.Now().Add(claimLease)).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range deliveries {
+		d.attempt(&deliveries[i])
+	}
+
+	return len(deliveries), nil
+}
+
+// attempt sends a delivery once, records the attempt and schedules a retry if needed
+func (d *Dispatcher) attempt(delivery *models.WebhookDelivery) {
+	var hook models.OutgoingWebhook
+	if err := d.db.First(&hook, delivery.WebhookID).Error; err != nil {
+		d.db.Model(delivery).Updates(map[string]interface{}{
+			"status":     models.DeliveryStatusFailed,
+			"last_error": "webhook no longer exists",
+		})
+		return
+	}
+
+	// Deactivating a webhook cancels the deliveries still queued for it
+	if !hook.Active {
+		d.db.Model(delivery).Updates(map[string]interface{}{
+			"status":     models.DeliveryStatusCancelled,
+			"last_error": "webhook is inactive",
+		})
+		return
+	}
+
+	start := time.Now()
+	statusCode, responseBody, sendErr := d.send(&hook, delivery)
+
+	record := models.WebhookDeliveryAttempt{
+		DeliveryID:   delivery.ID,
+		StatusCode:   statusCode,
+		ResponseBody: responseBody,
+		DurationMs:   time.Since(start).Milliseconds(),
+	}
+	if sendErr != nil {
+		record.Error = sendErr.Error()
+	}
+	if err := d.db.Create(&record).Error; err != nil {
+		log.Printf("Failed to record webhook attempt for delivery %d: %v", delivery.ID, err)
+	}
+
+	attempts := delivery.Attempts + 1
+	updates := map[string]interface{}{
+		"attempts":         attempts,
+		"last_status_code": statusCode,
+		"last_error":       record.Error,
+	}
+
+	switch {
+	case sendErr == nil:
+		updates["status"] = models.DeliveryStatusSucceeded
+		updates["delivered_at"] = time.Now()
+	case attempts >= maxAttempts:
+		updates["status"] = models.DeliveryStatusFailed
+		log.Printf("Webhook delivery %d failed after %d attempts: %v", delivery.ID, attempts, sendErr)
+	default:
+		updates["next_attempt_at"] = time.Now().Add(Backoff(attempts))
+	}
+
+	if err := d.db.Model(delivery).Updates(updates).Error; err != nil {
+		log.Printf("Failed to update webhook delivery %d: %v", delivery.ID, err)
+	}
+}
+
+// send POSTs the signed payload, treating any non-2xx answer as a failure
+func (d *Dispatcher) send(hook *models.OutgoingWebhook, delivery *models.WebhookDelivery) (int, string, error) {
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Pictorial-Webhooks/1.0")
+	req.Header.Set("X-Pictorial-Event", delivery.EventType)
+	req.Header.Set("X-Pictorial-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set("X-Pictorial-Timestamp", timestamp)
+	req.Header.Set("X-Pictorial-Signature", "sha256="+Sign(hook.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	recorded, _ := io.ReadAll(io.LimitReader(resp.Body, maxRecordedResponse))
+	// Drain the rest so the connection can be reused
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, string(recorded), fmt.Errorf("receiver answered %s", resp.Status)
+	}
+	return resp.StatusCode, string(recorded), nil
+}
+
+// Sign computes the hex encoded HMAC-SHA256 of "<timestamp>.<body>" with the webhook secret
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff returns the delay before the next attempt after the given number of failed attempts
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		return baseBackoff
+	}
+
+	delay := baseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return delay
+}
+
+// IsEventType checks if an event type can be subscribed to
+func IsEventType(eventType string) bool {
+	for _, known := range EventTypes {
+		if known == eventType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,249 @@
+package webhooks
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"pictorial-backend/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDispatcher returns a dispatcher backed by an empty in-memory database
+func newTestDispatcher(t *testing.T) *Dispatcher {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	// Every connection to :memory: is a separate database
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(&models.OutgoingWebhook{}, &models.WebhookDelivery{}, &models.WebhookDeliveryAttempt{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return NewDispatcher(db)
+}
+
+// receiver starts a local webhook receiver answering with the given statuses in turn, then 204
+func receiver(t *testing.T, statuses ...int) (*httptest.Server, *int32) {
+	t.Helper()
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		if int(n) <= len(statuses) {
+			w.WriteHeader(statuses[n-1])
+			io.WriteString(w, "try again later")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func createHook(t *testing.T, d *Dispatcher, url string, active bool) *models.OutgoingWebhook {
+	t.Helper()
+
+	hook := models.OutgoingWebhook{URL: url, Secret: "s3cret", Events: EventMessageCreated, Active: active, CreatorID: 1}
+	if err := d.db.Create(&hook).Error; err != nil {
+		t.Fatalf("create webhook: %v", err)
+	}
+	return &hook
+}
+
+func queueDelivery(t *testing.T, d *Dispatcher, hook *models.OutgoingWebhook, attempts int) *models.WebhookDelivery {
+	t.Helper()
+
+	delivery := models.WebhookDelivery{
+		WebhookID:     hook.ID,
+		EventType:     EventMessageCreated,
+		Payload:       models.RawJSON(`{"event":"message.created","data":{"id":1}}`),
+		Status:        models.DeliveryStatusPending,
+		Attempts:      attempts,
+		NextAttemptAt: time.Now(),
+	}
+	if err := d.db.Create(&delivery).Error; err != nil {
+		t.Fatalf("create delivery: %v", err)
+	}
+	return &delivery
+}
+
+func reload(t *testing.T, d *Dispatcher, delivery *models.WebhookDelivery) models.WebhookDelivery {
+	t.Helper()
+
+	var stored models.WebhookDelivery
+	if err := d.db.Preload("DeliveryLog").First(&stored, delivery.ID).Error; err != nil {
+		t.Fatalf("reload delivery: %v", err)
+	}
+	return stored
+}
+
+func TestSendSignsPayload(t *testing.T) {
+	hook := &models.OutgoingWebhook{Secret: "s3cret"}
+	delivery := &models.WebhookDelivery{
+		ID:        57,
+		EventType: EventMessageCreated,
+		Payload:   models.RawJSON(`{"event":"message.created","data":{"id":1}}`),
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != string(delivery.Payload) {
+			t.Errorf("body = %s, want %s", body, delivery.Payload)
+		}
+		if got := r.Header.Get("X-Pictorial-Event"); got != EventMessageCreated {
+			t.Errorf("X-Pictorial-Event = %q", got)
+		}
+		if got := r.Header.Get("X-Pictorial-Delivery"); got != "57" {
+			t.Errorf("X-Pictorial-Delivery = %q", got)
+		}
+
+		timestamp := r.Header.Get("X-Pictorial-Timestamp")
+		want := "sha256=" + Sign(hook.Secret, timestamp, body)
+		if got := r.Header.Get("X-Pictorial-Signature"); got != want {
+			t.Errorf("X-Pictorial-Signature = %q, want %q", got, want)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	hook.URL = server.URL
+
+	status, _, err := NewDispatcher(nil).send(hook, delivery)
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if status != http.StatusNoContent {
+		t.Errorf("status = %d, want %d", status, http.StatusNoContent)
+	}
+}
+
+func TestAttemptRetriesWithBackoffUntilSuccess(t *testing.T) {
+	d := newTestDispatcher(t)
+	server, requests := receiver(t, http.StatusBadGateway, http.StatusBadGateway)
+	delivery := queueDelivery(t, d, createHook(t, d, server.URL, true), 0)
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		before := time.Now()
+		d.attempt(delivery)
+		stored := reload(t, d, delivery)
+
+		if stored.Status != models.DeliveryStatusPending || stored.Attempts != attempt || stored.LastStatusCode != http.StatusBadGateway {
+			t.Fatalf("after attempt %d: status %q, attempts %d, last status %d", attempt, stored.Status, stored.Attempts, stored.LastStatusCode)
+		}
+		if wait := stored.NextAttemptAt.Sub(before); wait < Backoff(attempt) || wait > Backoff(attempt)+time.Minute {
+			t.Errorf("after attempt %d: next attempt in %s, want %s", attempt, wait, Backoff(attempt))
+		}
+		*delivery = stored
+	}
+
+	d.attempt(delivery)
+	stored := reload(t, d, delivery)
+	if stored.Status != models.DeliveryStatusSucceeded || stored.Attempts != 3 || stored.DeliveredAt == nil {
+		t.Errorf("after success: status %q, attempts %d, delivered at %v", stored.Status, stored.Attempts, stored.DeliveredAt)
+	}
+	if len(stored.DeliveryLog) != 3 || stored.DeliveryLog[0].ResponseBody != "try again later" {
+		t.Errorf("recorded %d attempts, want 3 with the response bodies", len(stored.DeliveryLog))
+	}
+	if got := atomic.LoadInt32(requests); got != 3 {
+		t.Errorf("receiver got %d requests, want 3", got)
+	}
+}
+
+func TestAttemptFailsAfterMaxAttempts(t *testing.T) {
+	d := newTestDispatcher(t)
+	server, _ := receiver(t, http.StatusInternalServerError)
+	delivery := queueDelivery(t, d, createHook(t, d, server.URL, true), maxAttempts-1)
+
+	d.attempt(delivery)
+	stored := reload(t, d, delivery)
+	if stored.Status != models.DeliveryStatusFailed || stored.Attempts != maxAttempts {
+		t.Errorf("status %q, attempts %d, want failed after %d", stored.Status, stored.Attempts, maxAttempts)
+	}
+}
+
+func TestAttemptCancelsDeliveriesOfInactiveWebhooks(t *testing.T) {
+	d := newTestDispatcher(t)
+	server, requests := receiver(t)
+	delivery := queueDelivery(t, d, createHook(t, d, server.URL, false), 0)
+
+	d.attempt(delivery)
+	stored := reload(t, d, delivery)
+	if stored.Status != models.DeliveryStatusCancelled || stored.Attempts != 0 {
+		t.Errorf("status %q, attempts %d, want cancelled without attempts", stored.Status, stored.Attempts)
+	}
+	if got := atomic.LoadInt32(requests); got != 0 {
+		t.Errorf("receiver got %d requests, want none", got)
+	}
+}
+
+func TestProcessBatchDeliversQueuedEvents(t *testing.T) {
+	d := newTestDispatcher(t)
+	server, requests := receiver(t)
+	active := createHook(t, d, server.URL, true)
+	createHook(t, d, server.URL, false)
+
+	if err := d.Enqueue(EventMessageCreated, map[string]interface{}{"id": 1}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if err := d.Enqueue(EventChannelCreated, map[string]interface{}{"id": 1}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	processed, err := d.processBatch()
+	if err != nil {
+		t.Fatalf("process batch: %v", err)
+	}
+	if processed != 1 {
+		t.Fatalf("processed %d deliveries, want 1 for the only subscribed active webhook", processed)
+	}
+
+	var deliveries []models.WebhookDelivery
+	d.db.Find(&deliveries)
+	if len(deliveries) != 1 || deliveries[0].WebhookID != active.ID || deliveries[0].Status != models.DeliveryStatusSucceeded {
+		t.Errorf("deliveries = %+v, want one succeeded delivery to webhook %d", deliveries, active.ID)
+	}
+	if got := atomic.LoadInt32(requests); got != 1 {
+		t.Errorf("receiver got %d requests, want 1", got)
+	}
+
+	// Nothing is due anymore
+	if processed, err := d.processBatch(); err != nil || processed != 0 {
+		t.Errorf("second batch processed %d deliveries (%v), want 0", processed, err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, 10 * time.Second},
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{9, 2560 * time.Second},
+		{10, time.Hour},
+		{50, time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
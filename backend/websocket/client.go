@@ -78,6 +78,14 @@ func (c *Client) ReadPump() {
 
 		switch msg.Type {
 		case "subscribe":
+			if err := c.hub.AuthorizeSubscription(c.userID, msg.ChannelID); err != nil {
+				c.hub.SendToClient(c, Event{
+					Type:      EventError,
+					ChannelID: msg.ChannelID,
+					Data:      map[string]string{"request": msg.Type, "error": err.Error()},
+				})
+				continue
+			}
 			c.hub.SubscribeToChannel(c.userID, msg.ChannelID)
 		case "unsubscribe":
 			c.hub.UnsubscribeFromChannel(c.userID, msg.ChannelID)
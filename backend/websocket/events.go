@@ -0,0 +1,66 @@
+package websocket
+
+// Event is a typed notification pushed to clients. Newly created messages are
+// still sent as plain message payloads; everything else is wrapped in an Event.
+type Event struct {
+	Type      string      `json:"type"`
+	ChannelID uint        `json:"channel_id,omitempty"`
+	Data      interface{} `json:"data,omitempty"`
+}
+
+// Event types sent to clients
+const (
+	// EventError reports a client request the server rejected
+	EventError = "error"
+
+	// EventMessageEdited carries a message after its content or drawing was edited
+	EventMessageEdited = "message_edited"
+
+	// EventReactionAdded carries a reaction added to a message and the new count of its kind
+	EventReactionAdded = "reaction_added"
+
+	// EventReactionRemoved carries a reaction removed from a message and the new count of its kind
+	EventReactionRemoved = "reaction_removed"
+
+	// EventThreadUpdated carries the new reply count and last reply time of a thread
+	EventThreadUpdated = "thread_updated"
+
+	// EventMention carries a message mentioning the user it is sent to, on all of their connections
+	EventMention = "mention"
+
+	// EventReadMarkerUpdated carries a user's new read marker in a channel, sent to all of their connections
+	EventReadMarkerUpdated = "read_marker_updated"
+
+	// EventScheduledMessageFailed carries a scheduled message that could not be posted, sent to its author
+	EventScheduledMessageFailed = "scheduled_message_failed"
+
+	// EventMessagesBulkDeleted carries the IDs of the messages of a channel deleted at once by an admin
+	EventMessagesBulkDeleted = "messages_bulk_deleted"
+
+	// EventMessageRestored carries a deleted message brought back by a moderator
+	EventMessageRestored = "message_restored"
+
+	// EventMessageExpired carries the ID of a message whose time-to-live passed, to be removed by clients
+	EventMessageExpired = "message_expired"
+
+	// EventReportCreated carries a new message report, sent to moderators
+	EventReportCreated = "report_created"
+
+	// EventReportResolved carries the outcome of a report, sent to the user who filed it
+	EventReportResolved = "report_resolved"
+
+	// EventBanned carries the ban of the user it is sent to, right before their connections are closed
+	EventBanned = "banned"
+
+	// EventSanctioned carries a mute issued against the user it is sent to
+	EventSanctioned = "sanctioned"
+
+	// EventMessagePinned carries a message that was pinned in a channel
+	EventMessagePinned = "message_pinned"
+
+	// EventMessageUnpinned carries the ID of a message removed from a channel's pins
+	EventMessageUnpinned = "message_unpinned"
+
+	// EventChannelTopicUpdated carries a channel's new topic
+	EventChannelTopicUpdated = "channel_topic_updated"
+)
@@ -28,9 +28,29 @@ type Hub struct {
 	// Broadcast messages to clients in a specific channel
 	broadcast chan *BroadcastMessage
 
+	// Messages addressed to a single client connection or to all of a user's connections
+	direct chan *DirectMessage
+
+	// Closes every connection of a user
+	disconnect chan *DirectMessage
+
+	// Decides whether a user may subscribe to a channel
+	authorizeSubscribe SubscribeAuthorizer
+
 	mu sync.RWMutex
 }
 
+// SubscribeAuthorizer returns an error describing why a user may not subscribe to a channel
+type SubscribeAuthorizer func(userID uint, channelID uint) error
+
+// DirectMessage represents a message to be sent to a single client connection,
+// or to every connection of a user when Client is nil
+type DirectMessage struct {
+	Client  *Client
+	UserID  uint
+	Message interface{}
+}
+
 // BroadcastMessage represents a message to be broadcast to a channel
 type BroadcastMessage struct {
 	ChannelID uint
@@ -53,6 +73,8 @@ func NewHub() *Hub {
 		subscribe:     make(chan *Subscription),
 		unsubscribe:   make(chan *Subscription),
 		broadcast:     make(chan *BroadcastMessage),
+		direct:        make(chan *DirectMessage),
+		disconnect:    make(chan *DirectMessage),
 	}
 }
 
@@ -71,20 +93,14 @@ func (h *Hub) Run() {
 
 		case client := <-h.unregister:
 			h.mu.Lock()
-			if clientSet, ok := h.clients[client.userID]; ok {
+			// A client can be unregistered twice (full buffer, then closed connection), its channel is closed once
+			if clientSet, ok := h.clients[client.userID]; ok && clientSet[client] {
 				delete(clientSet, client)
 				close(client.send)
 
 				// If user has no more connections, remove from subscriptions
 				if len(clientSet) == 0 {
-					delete(h.clients, client.userID)
-					// Remove user from all channel subscriptions
-					for channelID, subscribers := range h.subscriptions {
-						delete(subscribers, client.userID)
-						if len(subscribers) == 0 {
-							delete(h.subscriptions, channelID)
-						}
-					}
+					h.removeUser(client.userID)
 					log.Printf("Last client unregistered for user %d", client.userID)
 				} else {
 					log.Printf("Client unregistered for user %d (remaining connections: %d)", client.userID, len(clientSet))
@@ -92,6 +108,25 @@ func (h *Hub) Run() {
 			}
 			h.mu.Unlock()
 
+		case message := <-h.disconnect:
+			h.mu.Lock()
+			clientSet := h.clients[message.UserID]
+			for client := range clientSet {
+				// The last message is written before the connection is closed
+				if message.Message != nil {
+					select {
+					case client.send <- message.Message:
+					default:
+					}
+				}
+				close(client.send)
+			}
+			h.removeUser(message.UserID)
+			h.mu.Unlock()
+			if len(clientSet) > 0 {
+				log.Printf("Disconnected %d clients of user %d", len(clientSet), message.UserID)
+			}
+
 		case sub := <-h.subscribe:
 			h.mu.Lock()
 			if h.subscriptions[sub.ChannelID] == nil {
@@ -137,10 +172,120 @@ func (h *Hub) Run() {
 					}
 				}
 			}
+
+		case message := <-h.direct:
+			if message.Client == nil {
+				h.mu.RLock()
+				clientSet := h.clients[message.UserID]
+				h.mu.RUnlock()
+
+				for client := range clientSet {
+					select {
+					case client.send <- message.Message:
+					default:
+						log.Printf("Client send buffer full, dropping direct message for user %d", message.UserID)
+					}
+				}
+				continue
+			}
+
+			h.mu.RLock()
+			_, ok := h.clients[message.Client.userID][message.Client]
+			h.mu.RUnlock()
+
+			// The client may have been unregistered in the meantime
+			if ok {
+				select {
+				case message.Client.send <- message.Message:
+				default:
+					log.Printf("Client send buffer full, dropping direct message for user %d", message.Client.userID)
+				}
+			}
 		}
 	}
 }
 
+// removeUser forgets a user's connections and channel subscriptions. The caller must hold the lock.
+func (h *Hub) removeUser(userID uint) {
+	delete(h.clients, userID)
+	for channelID, subscribers := range h.subscriptions {
+		delete(subscribers, userID)
+		if len(subscribers) == 0 {
+			delete(h.subscriptions, channelID)
+		}
+	}
+}
+
+// ChannelOccupants returns the IDs of the users currently subscribed to a channel
+func (h *Hub) ChannelOccupants(channelID uint) []uint {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	occupants := make([]uint, 0, len(h.subscriptions[channelID]))
+	for userID := range h.subscriptions[channelID] {
+		occupants = append(occupants, userID)
+	}
+	return occupants
+}
+
+// OccupancyByChannel returns the number of users subscribed to each channel
+func (h *Hub) OccupancyByChannel() map[uint]int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	occupancy := make(map[uint]int, len(h.subscriptions))
+	for channelID, subscribers := range h.subscriptions {
+		occupancy[channelID] = len(subscribers)
+	}
+	return occupancy
+}
+
+// OnlineUserCount returns the number of users with at least one open connection
+func (h *Hub) OnlineUserCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.clients)
+}
+
+// SetSubscribeAuthorizer sets the check run before a user subscribes to a channel.
+// It must be called before Run.
+func (h *Hub) SetSubscribeAuthorizer(authorize SubscribeAuthorizer) {
+	h.authorizeSubscribe = authorize
+}
+
+// AuthorizeSubscription runs the subscribe authorizer, allowing everything when none is set
+func (h *Hub) AuthorizeSubscription(userID uint, channelID uint) error {
+	if h.authorizeSubscribe == nil {
+		return nil
+	}
+	return h.authorizeSubscribe(userID, channelID)
+}
+
+// SendToClient sends a message to a single client connection
+func (h *Hub) SendToClient(client *Client, message interface{}) {
+	h.direct <- &DirectMessage{
+		Client:  client,
+		Message: message,
+	}
+}
+
+// SendToUser sends a message to every connection of a user, whatever channels they are subscribed to
+func (h *Hub) SendToUser(userID uint, message interface{}) {
+	h.direct <- &DirectMessage{
+		UserID:  userID,
+		Message: message,
+	}
+}
+
+// DisconnectUser closes every connection of a user, after sending them a last message when it is not nil
+func (h *Hub) DisconnectUser(userID uint, message interface{}) {
+	h.disconnect <- &DirectMessage{
+		UserID:  userID,
+		Message: message,
+	}
+}
+
 // BroadcastToChannel sends a message to all clients subscribed to a specific channel
 func (h *Hub) BroadcastToChannel(channelID uint, message interface{}) {
 	h.broadcast <- &BroadcastMessage{
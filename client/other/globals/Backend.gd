@@ -1,6 +1,7 @@
 extends Node
 
 signal new_message(message: Dictionary)
+signal hub_event(event: Dictionary)
 signal logged_in
 
 # TODO: save credentials in local storage
@@ -127,13 +128,16 @@ func delete_channel(id: int) -> bool: ## DELETE /api/v1/channels/:id
 			Log.error(_baseUrl + "/api/v1/channels/" + str(id) + " : " + resp.body_as_variant()["error"])
 	return false
 
-func get_channel_messages(channelId: int, page: int = 1, limit: int = 20) -> Array: ## GET /api/v1/channels/:id/messages?page=1&limit=50
+func get_channel_messages(channelId: int, before: int = 0, limit: int = 20) -> Array: ## GET /api/v1/channels/:id/messages?before=120&limit=50
+	var url : String = _baseUrl + "/api/v1/channels/" + str(channelId) + "/messages?limit=" + str(limit)
+	if before > 0:
+		url += "&before=" + str(before)
 	var resp: HTTPResult = await async_request.async_request_strap(
-		self, _baseUrl + "/api/v1/channels/" + str(channelId) + "/messages?page=" + str(page) + "&limit=" + str(limit), ["Authorization: Bearer " + _jwt])
+		self, url, ["Authorization: Bearer " + _jwt])
 	if resp.success():
 		if resp.status_ok():
 			if resp.body_as_variant():
-				var r : Array = resp.body_as_variant() as Array
+				var r : Array = resp.body_as_variant()["messages"] as Array
 				Log.pr(r)
 				return r
 		else:
@@ -150,9 +154,14 @@ func create_message(channelId: int, nbOfLines: int, text, image) -> bool: ## POS
 		image = image as Image
 		var image64 : String = Marshalls.raw_to_base64(image.save_png_to_buffer())
 		b.set("image_data", image64)
+	# The nonce lets the server recognize a retry and not post the message twice
+	b.set("nonce", str(Time.get_unix_time_from_system()) + "-" + str(randi()))
 	var body : String = JSON.stringify(b)
 	var resp: HTTPResult = await async_request.async_request_strap(
 		self, _baseUrl + "/api/v1/messages", ["Authorization: Bearer " + _jwt], HTTPClient.METHOD_POST, body)
+	if not resp.success():
+		resp = await async_request.async_request_strap(
+			self, _baseUrl + "/api/v1/messages", ["Authorization: Bearer " + _jwt], HTTPClient.METHOD_POST, body)
 	if resp.success():
 		if resp.status_ok():
 			var r : Dictionary = resp.body_as_variant() as Dictionary
@@ -230,7 +239,11 @@ func _process(delta: float) -> void:
 		while _socket.get_available_packet_count():
 			var packet : Dictionary = JSON.parse_string(_socket.get_packet().get_string_from_utf8())
 			Log.pr(packet)
-			new_message.emit(packet)
+			# typed events carry a "type", new messages are sent as is
+			if packet.has("type"):
+				hub_event.emit(packet)
+			else:
+				new_message.emit(packet)
 	elif state == WebSocketPeer.STATE_CLOSING:
 		# Keep polling to achieve proper close.
 		pass